@@ -0,0 +1,190 @@
+package go2def
+
+import (
+	"bytes"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aarzilli/go2def/visit"
+	"golang.org/x/tools/go/packages"
+)
+
+// maxCacheEntries is the maximum number of package sets kept by a Cache.
+const maxCacheEntries = 8
+
+// maxFileSetGrowth is the maximum number of bytes that can be added to the
+// file set of a cache entry, by loading the syntax of packages that were
+// loaded without it, before the entry is discarded.
+const maxFileSetGrowth = 64 << 20
+
+// Cache keeps loaded packages between calls to Describe so that a long
+// running process (like the daemon) does not have to call packages.Load for
+// every query.
+// A cache entry is reused only if none of the files of the loaded packages
+// changed on disk, no file was added to or removed from their directories,
+// go.mod and go.sum did not change and the modified files passed in
+// Config.Modfiles are the same ones that were used to load it.
+// Every query that needs the syntax of a dependency adds its files to the
+// file set of the entry, entries whose file set grew too much are discarded
+// and loaded again.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[cacheKey]*cacheEntry
+}
+
+type cacheKey struct {
+	dir, wd    string
+	tests      bool
+	env        string
+	buildFlags string
+}
+
+type cacheEntry struct {
+	fset     *token.FileSet
+	base     int // base of fset when the entry was added
+	pkgs     []*packages.Package
+	modfiles map[string][]byte
+	sources  map[string][]byte // source of the parsed files
+	mtimes   map[string]time.Time
+	used     time.Time
+}
+
+func newCacheKey(cfg *packages.Config, path string) cacheKey {
+	return cacheKey{
+		dir:        filepath.Dir(path),
+		wd:         cfg.Dir,
+		tests:      cfg.Tests,
+		env:        strings.Join(cfg.Env, "\x00"),
+		buildFlags: strings.Join(cfg.BuildFlags, "\x00"),
+	}
+}
+
+// lookup returns the packages loaded for path with cfg, and the source of
+// the files that were parsed to load them, if they are still valid.
+func (cache *Cache) lookup(cfg *packages.Config, path string, modfiles map[string][]byte) (*token.FileSet, []*packages.Package, map[string][]byte, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	key := newCacheKey(cfg, path)
+	entry := cache.entries[key]
+	if entry == nil {
+		return nil, nil, nil, false
+	}
+	if !sameModfiles(entry.modfiles, modfiles) || entry.stale() || entry.fset.Base()-entry.base > maxFileSetGrowth {
+		delete(cache.entries, key)
+		return nil, nil, nil, false
+	}
+	entry.used = time.Now()
+	sources := make(map[string][]byte, len(entry.sources))
+	for name, buf := range entry.sources {
+		sources[name] = buf
+	}
+	return entry.fset, entry.pkgs, sources, true
+}
+
+// add saves pkgs, loaded for path with cfg, into the cache.
+func (cache *Cache) add(ctx *context, cfg *packages.Config, path string, pkgs []*packages.Package) {
+	entry := &cacheEntry{
+		fset:     cfg.Fset,
+		base:     cfg.Fset.Base(),
+		pkgs:     pkgs,
+		modfiles: make(map[string][]byte, len(ctx.Modfiles)),
+		sources:  make(map[string][]byte, len(ctx.sources)),
+		mtimes:   make(map[string]time.Time),
+		used:     time.Now(),
+	}
+	for name, buf := range ctx.Modfiles {
+		entry.modfiles[name] = buf
+	}
+	for name, buf := range ctx.sources {
+		entry.sources[name] = buf
+	}
+
+	// Files added to or removed from a package change the modification
+	// time of its directory, go.mod and go.sum change the dependencies.
+	root := moduleRoot(cfg.Dir)
+	entry.record(filepath.Join(root, "go.mod"))
+	entry.record(filepath.Join(root, "go.sum"))
+	entry.record(filepath.Dir(path))
+
+	goroot := ctx.Goroot()
+	pkgit := visit.Packages(pkgs)
+	for pkgit.Next() {
+		pkg := pkgit.Pkg()
+		if pkg == nil {
+			continue
+		}
+		for _, filename := range pkg.GoFiles {
+			if goroot != "" && strings.HasPrefix(filename, goroot) {
+				continue
+			}
+			entry.record(filename)
+			entry.record(filepath.Dir(filename))
+		}
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.entries == nil {
+		cache.entries = make(map[cacheKey]*cacheEntry)
+	}
+	if len(cache.entries) >= maxCacheEntries {
+		var oldest cacheKey
+		var oldestEntry *cacheEntry
+		for key, entry := range cache.entries {
+			if oldestEntry == nil || entry.used.Before(oldestEntry.used) {
+				oldest, oldestEntry = key, entry
+			}
+		}
+		delete(cache.entries, oldest)
+	}
+	cache.entries[newCacheKey(cfg, path)] = entry
+}
+
+// record saves the modification time of filename, a zero time if it does
+// not exist.
+func (entry *cacheEntry) record(filename string) {
+	if _, ok := entry.mtimes[filename]; ok {
+		return
+	}
+	var mtime time.Time
+	if fi, err := os.Stat(filename); err == nil {
+		mtime = fi.ModTime()
+	}
+	entry.mtimes[filename] = mtime
+}
+
+// stale returns true if any of the files or directories used to load the
+// entry changed, was created or was removed.
+func (entry *cacheEntry) stale() bool {
+	for filename, mtime := range entry.mtimes {
+		fi, err := os.Stat(filename)
+		if err != nil {
+			if !mtime.IsZero() {
+				return true
+			}
+			continue
+		}
+		if !fi.ModTime().Equal(mtime) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameModfiles(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, buf := range a {
+		if buf2, ok := b[name]; !ok || !bytes.Equal(buf, buf2) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,213 @@
+package go2def
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// call is a static call found in the workspace.
+type call struct {
+	pos        token.Position // position of the call expression
+	caller     objKey         // function containing the call
+	callerName string
+	callee     objKey // function called
+	calleeName string
+	dynamic    bool // the callee is an interface method
+}
+
+// Callers lists every call, in all the packages (including tests) of the
+// module containing path, to the function or method at the specified
+// position, with the function containing each call. If cfg.Depth is greater
+// than 1 the callers of each caller are listed too, up to cfg.Depth levels.
+// Only static calls are considered: calls to an interface method are listed
+// as calls of the interface method, not of its implementations.
+func Callers(path string, pos [2]int, cfg *Config) Description {
+	return callHierarchy(path, pos, cfg, true)
+}
+
+// Callees lists the functions and methods statically called by the body of
+// the function or method at the specified position. If cfg.Depth is greater
+// than 1 the callees of each callee are listed too, up to cfg.Depth levels.
+// Calls to interface methods are marked as dynamic.
+func Callees(path string, pos [2]int, cfg *Config) Description {
+	return callHierarchy(path, pos, cfg, false)
+}
+
+func callHierarchy(path string, pos [2]int, cfg *Config, callers bool) Description {
+	ctx := newContext(path, cfg)
+
+	_, obj, err := objectAt(ctx, path, pos, true)
+	if err != nil {
+		ctx.out.err("%v", err)
+		ctx.writeOut(true)
+		return ctx.out
+	}
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		ctx.out.err("%s is not a function", obj.Name())
+		ctx.writeOut(true)
+		return ctx.out
+	}
+
+	depth := ctx.Depth
+	if depth < 1 {
+		depth = 1
+	}
+
+	w := &callTreeWalker{ctx: ctx, calls: findCalls(ctx), callers: callers, depth: depth, stack: make(map[objKey]bool)}
+	ctx.out.object(fn)
+	w.walk(ctx.objKey(fn), 0)
+
+	ctx.writeOut(true)
+	return ctx.out
+}
+
+// callTreeWalker writes the tree of callers, or callees, of a function.
+type callTreeWalker struct {
+	ctx     *context
+	calls   []*call
+	callers bool // walk callers instead of callees
+	depth   int  // maximum depth of the tree
+	stack   map[objKey]bool
+}
+
+// edge returns the function c starts from, the function it leads to and the
+// name of the latter, when walking in the direction of w.
+func (w *callTreeWalker) edge(c *call) (from, to objKey, name string) {
+	if w.callers {
+		return c.callee, c.caller, c.callerName
+	}
+	name = c.calleeName
+	if c.dynamic {
+		name += " (dynamic)"
+	}
+	return c.caller, c.callee, name
+}
+
+func (w *callTreeWalker) walk(key objKey, level int) {
+	w.stack[key] = true
+	defer delete(w.stack, key)
+
+	seen := make(map[objKey]bool)
+	for _, c := range w.calls {
+		from, to, name := w.edge(c)
+		if from != key {
+			continue
+		}
+		if !w.callers {
+			// every callee is listed once, at its first call
+			if seen[to] {
+				continue
+			}
+			seen[to] = true
+		}
+		if w.stack[to] {
+			w.ctx.out.call(name+" (recursive)", c.pos, level)
+			continue
+		}
+		w.ctx.out.call(name, c.pos, level)
+		if level+1 < w.depth && !c.dynamic {
+			w.walk(to, level+1)
+		}
+	}
+}
+
+// findCalls returns all the static calls in the workspace, outside of the
+// standard library, sorted by position.
+func findCalls(ctx *context) []*call {
+	calls := []*call{}
+	seen := make(map[token.Position]bool)
+
+	workspacePackages(ctx, func(pkg *packages.Package) {
+		if ctx.isStdlib(pkg) {
+			return
+		}
+		initKey := objKey{name: "init", filename: pkg.PkgPath}
+		initName := pkg.Name + ".init"
+
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				caller, callerName := initKey, initName
+				if decl, isfunc := decl.(*ast.FuncDecl); isfunc {
+					fn, _ := pkg.TypesInfo.Defs[decl.Name].(*types.Func)
+					if fn == nil {
+						continue
+					}
+					caller, callerName = ctx.objKey(fn), funcName(fn)
+				}
+
+				ast.Inspect(decl, func(node ast.Node) bool {
+					callExpr, ok := node.(*ast.CallExpr)
+					if !ok {
+						return true
+					}
+					fn, dynamic := staticCallee(pkg, callExpr)
+					if fn == nil {
+						return true
+					}
+					pos := ctx.position(callExpr.Pos())
+					if seen[pos] {
+						// same call in a different variant of the package
+						return true
+					}
+					seen[pos] = true
+					calls = append(calls, &call{
+						pos:        pos,
+						caller:     caller,
+						callerName: callerName,
+						callee:     ctx.objKey(fn),
+						calleeName: funcName(fn),
+						dynamic:    dynamic,
+					})
+					return true
+				})
+			}
+		}
+	})
+
+	sort.Slice(calls, func(i, j int) bool {
+		a, b := calls[i].pos, calls[j].pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+
+	return calls
+}
+
+// staticCallee returns the function called by callExpr, if it is known
+// statically. If the function is an interface method dynamic is true.
+func staticCallee(pkg *packages.Package, callExpr *ast.CallExpr) (fn *types.Func, dynamic bool) {
+	switch fun := unparen(callExpr.Fun).(type) {
+	case *ast.Ident:
+		fn, _ = pkg.TypesInfo.Uses[fun].(*types.Func)
+	case *ast.SelectorExpr:
+		if sel := pkg.TypesInfo.Selections[fun]; sel != nil {
+			if sel.Kind() == types.FieldVal {
+				return nil, false
+			}
+			fn, _ = sel.Obj().(*types.Func)
+			return fn, fn != nil && types.IsInterface(sel.Recv())
+		}
+		// qualified identifier
+		fn, _ = pkg.TypesInfo.Uses[fun.Sel].(*types.Func)
+	}
+	return fn, false
+}
+
+// funcName returns the name of fn qualified by its package name, or by its
+// receiver type if it is a method.
+func funcName(fn *types.Func) string {
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		return "(" + printTypesTypeNice(recv.Type()) + ")." + fn.Name()
+	}
+	if fn.Pkg() != nil {
+		return fn.Pkg().Name() + "." + fn.Name()
+	}
+	return fn.Name()
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/aarzilli/go2def"
+)
+
+// socketPath returns the path of the unix socket used by the daemon of the
+// current user. The socket is in a directory that only the current user can
+// access: $XDG_RUNTIME_DIR/go2def or, if it isn't set, a go2def directory in
+// the user cache directory.
+func socketPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		var err error
+		dir, err = os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+	}
+	dir = filepath.Join(dir, "go2def")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() || !privateToUser(fi) {
+		return "", fmt.Errorf("%s must be a directory owned by the current user and not accessible by others", dir)
+	}
+	return filepath.Join(dir, "daemon.sock"), nil
+}
+
+// daemon listens on socketPath for requests until a quit request is
+// received. Requests are served one at a time, packages loaded by a request
+// are kept in a cache and reused by the ones that follow.
+//
+// A request is the working directory of the client followed by a list of
+// command line arguments, one per line, terminated by an empty line.
+// Everything after that is the standard input of the command.
+// The connection is closed after the output of the command is written.
+func daemon() {
+	path, err := socketPath()
+	if err != nil {
+		log.Fatalf("could not create the socket directory: %v", err)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		fmt.Printf("daemon already running\n")
+		os.Exit(1)
+	}
+	os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		log.Fatalf("could not listen on %s: %v", path, err)
+	}
+
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigch
+		listener.Close()
+	}()
+
+	cache := &go2def.Cache{}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if verbose {
+				log.Printf("accept: %v", err)
+			}
+			break
+		}
+		quit := serve(conn, cache)
+		conn.Close()
+		if quit {
+			break
+		}
+	}
+
+	listener.Close()
+	os.Remove(path)
+}
+
+// serve executes the request read from conn, returns true if the daemon
+// should stop.
+func serve(conn net.Conn, cache *go2def.Cache) bool {
+	rd := bufio.NewReader(conn)
+	wd, err := rd.ReadString('\n')
+	if err != nil {
+		log.Printf("error reading request: %v", err)
+		return false
+	}
+	wd = strings.TrimSuffix(wd, "\n")
+	args := []string{}
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			log.Printf("error reading request: %v", err)
+			return false
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+		args = append(args, line)
+	}
+
+	if len(args) == 0 {
+		return false
+	}
+
+	if verbose {
+		log.Printf("request %q %q", wd, args)
+	}
+
+	w := bufio.NewWriter(conn)
+	defer w.Flush()
+
+	if args[0] == "quit" {
+		return true
+	}
+	if _, ok := queries[args[0]]; ok {
+		query(w, rd, wd, args[0], args[1:], cache)
+	} else {
+		fmt.Fprintf(w, "unknown command: %q\n", args[0])
+	}
+	return false
+}
+
+// remote sends the command specified by args to the daemon and copies its
+// output to os.Stdout. If sendStdin is set the contents of os.Stdin are sent
+// along with the request. Returns false if the daemon could not be reached
+// or its socket isn't owned by the current user.
+func remote(args []string, sendStdin bool) bool {
+	path, err := socketPath()
+	if err != nil {
+		return false
+	}
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	if fi.Mode()&os.ModeSocket == 0 || !ownedByUser(fi) {
+		log.Printf("ignoring %s, it is not a socket owned by the current user", path)
+		return false
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return false
+	}
+
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "%s\n", wd)
+	for _, arg := range args {
+		if strings.Contains(arg, "\n") {
+			fmt.Printf("argument can not contain newlines: %q\n", arg)
+			return true
+		}
+		fmt.Fprintf(w, "%s\n", arg)
+	}
+	fmt.Fprintf(w, "\n")
+	if sendStdin {
+		if _, err := io.Copy(w, os.Stdin); err != nil {
+			log.Printf("error sending request: %v", err)
+			return true
+		}
+	}
+	if err := w.Flush(); err != nil {
+		log.Printf("error sending request: %v", err)
+		return true
+	}
+	if uconn, ok := conn.(*net.UnixConn); ok {
+		uconn.CloseWrite()
+	}
+
+	io.Copy(os.Stdout, conn)
+	return true
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSocketPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not checked on windows")
+	}
+	tmpdir, err := ioutil.TempDir(os.TempDir(), "go2def-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	defer os.Setenv("XDG_RUNTIME_DIR", os.Getenv("XDG_RUNTIME_DIR"))
+	os.Setenv("XDG_RUNTIME_DIR", tmpdir)
+
+	path, err := socketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(tmpdir, "go2def")
+	if filepath.Dir(path) != dir {
+		t.Errorf("wrong socket path %q", path)
+	}
+	fi, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0700 {
+		t.Errorf("wrong permissions of socket directory %o", perm)
+	}
+
+	// a regular file in place of the socket is not used
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if remote([]string{"describe"}, false) {
+		t.Errorf("request sent to a file that is not a socket")
+	}
+
+	os.Chmod(dir, 0755)
+	if _, err := socketPath(); err == nil {
+		t.Errorf("socket directory accessible by others accepted")
+	}
+}
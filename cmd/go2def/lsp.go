@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aarzilli/go2def"
+)
+
+// lspServer is a minimal Language Server Protocol server, answering
+// definition, hover and type definition requests with go2def.Describe.
+// Buffers opened by the client are passed to Describe as modified files.
+type lspServer struct {
+	rd    *bufio.Reader
+	out   io.Writer
+	cache *go2def.Cache
+
+	docs map[string][]byte // contents of open documents, by file path
+
+	shutdown bool
+}
+
+type lspRequest struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  json.RawMessage  `json:"result,omitempty"` // only set on success, can be null
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+	lspInvalidRequest = -32600
+	lspInternalError  = -32603
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type lspTextDocumentPositionParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+	Position     lspPosition               `json:"position"`
+}
+
+type lspDidOpenParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+}
+
+type lspDidChangeParams struct {
+	TextDocument   lspTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Range *lspRange `json:"range,omitempty"`
+		Text  string    `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspDidCloseParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+}
+
+type lspHover struct {
+	Contents struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	} `json:"contents"`
+}
+
+// lsp runs a language server on standard input and output.
+func lsp() {
+	srv := &lspServer{
+		rd:    bufio.NewReader(os.Stdin),
+		out:   os.Stdout,
+		cache: &go2def.Cache{},
+		docs:  make(map[string][]byte),
+	}
+	for {
+		req, err := srv.read()
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("error reading request: %v", err)
+			}
+			return
+		}
+		if verbose {
+			log.Printf("lsp request %s", req.Method)
+		}
+		if req.Method == "exit" {
+			if srv.shutdown {
+				os.Exit(0)
+			}
+			os.Exit(1)
+		}
+		result, rerr := srv.handle(req)
+		if req.ID == nil {
+			// notification
+			continue
+		}
+		srv.respond(req.ID, result, rerr)
+	}
+}
+
+// respond sends the response to the request with the specified id: rerr if
+// it isn't nil, result otherwise. A nil result is sent as null.
+func (srv *lspServer) respond(id *json.RawMessage, result interface{}, rerr *lspError) {
+	resp := &lspResponse{JSONRPC: "2.0", ID: id, Error: rerr}
+	if rerr == nil {
+		buf, err := json.Marshal(result)
+		if err != nil {
+			resp.Error = &lspError{Code: lspInternalError, Message: err.Error()}
+		} else {
+			resp.Result = buf
+		}
+	}
+	srv.write(resp)
+}
+
+// read reads one message, which is a list of headers followed by a JSON
+// body of the length specified in the Content-Length header.
+func (srv *lspServer) read() (*lspRequest, error) {
+	length := -1
+	for {
+		line, err := srv.rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("malformed header %q", line)
+		}
+		if strings.EqualFold(line[:colon], "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[colon+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("malformed header %q: %v", line, err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(srv.rd, body); err != nil {
+		return nil, err
+	}
+	req := &lspRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func (srv *lspServer) write(resp *lspResponse) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("error encoding response: %v", err)
+		return
+	}
+	fmt.Fprintf(srv.out, "Content-Length: %d\r\n\r\n", len(body))
+	srv.out.Write(body)
+}
+
+func (srv *lspServer) handle(req *lspRequest) (interface{}, *lspError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       1, // full
+				"definitionProvider":     true,
+				"hoverProvider":          true,
+				"typeDefinitionProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "go2def"},
+		}, nil
+
+	case "initialized":
+		return nil, nil
+
+	case "shutdown":
+		srv.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params lspDidOpenParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+		srv.docs[uriToPath(params.TextDocument.URI)] = []byte(params.TextDocument.Text)
+		return nil, nil
+
+	case "textDocument/didChange":
+		var params lspDidChangeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+		path := uriToPath(params.TextDocument.URI)
+		for _, change := range params.ContentChanges {
+			if change.Range != nil {
+				// we only advertise full synchronization
+				return nil, &lspError{lspInvalidParams, "incremental changes not supported"}
+			}
+			srv.docs[path] = []byte(change.Text)
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var params lspDidCloseParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+		delete(srv.docs, uriToPath(params.TextDocument.URI))
+		return nil, nil
+
+	case "textDocument/definition", "textDocument/typeDefinition", "textDocument/hover":
+		var params lspTextDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &lspError{lspInvalidParams, err.Error()}
+		}
+		descr, ok := srv.describe(&params)
+		if !ok {
+			return nil, nil
+		}
+		switch req.Method {
+		case "textDocument/definition":
+			return srv.definitionLocation(descr), nil
+		case "textDocument/typeDefinition":
+			return srv.typeDefinitionLocation(descr), nil
+		default:
+			return hover(descr), nil
+		}
+
+	default:
+		if req.ID == nil || strings.HasPrefix(req.Method, "$/") {
+			return nil, nil
+		}
+		if srv.shutdown {
+			return nil, &lspError{lspInvalidRequest, "server is shutting down"}
+		}
+		return nil, &lspError{lspMethodNotFound, fmt.Sprintf("method not supported: %s", req.Method)}
+	}
+}
+
+// describe calls go2def.Describe on the position specified by params.
+func (srv *lspServer) describe(params *lspTextDocumentPositionParams) (go2def.Description, bool) {
+	path := uriToPath(params.TextDocument.URI)
+	text, ok := srv.docs[path]
+	if !ok {
+		var err error
+		text, err = ioutil.ReadFile(path)
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	off, ok := utf16Offset(text, params.Position)
+	if !ok {
+		return nil, false
+	}
+
+	descr := go2def.Describe(path, [2]int{off, off}, &go2def.Config{Out: ioutil.Discard, Modfiles: srv.modified(), Cache: srv.cache})
+	return descr, len(descr) > 0
+}
+
+// modified returns the open documents whose contents differ from the file
+// on disk. Sending only those keeps the cache valid while other buffers are
+// open.
+func (srv *lspServer) modified() map[string][]byte {
+	var modfiles map[string][]byte
+	for path, text := range srv.docs {
+		if buf, err := ioutil.ReadFile(path); err == nil && bytes.Equal(buf, text) {
+			continue
+		}
+		if modfiles == nil {
+			modfiles = make(map[string][]byte)
+		}
+		modfiles[path] = text
+	}
+	return modfiles
+}
+
+// utf16Offset converts a LSP position, where the character is expressed in
+// UTF-16 code units, into a byte offset into text. Characters past the end
+// of the line, which editors send for example when the cursor is after
+// trailing whitespace that was removed, are moved to the end of the line.
+func utf16Offset(text []byte, pos lspPosition) (int, bool) {
+	if n, ok := utf16LineLen(text, pos.Line); ok && pos.Character > n {
+		pos.Character = n
+	}
+	off, err := go2def.LineColumnToOffset(text, pos.Line+1, pos.Character+1, go2def.ColumnUTF16)
+	return off, err == nil
+}
+
+// utf16LineLen returns the length of line, starting at 0, in UTF-16 code
+// units.
+func utf16LineLen(text []byte, line int) (int, bool) {
+	start := 0
+	for i := 0; i < line; i++ {
+		nl := bytes.IndexByte(text[start:], '\n')
+		if nl < 0 {
+			return 0, false
+		}
+		start += nl + 1
+	}
+	end := bytes.IndexByte(text[start:], '\n')
+	if end < 0 {
+		end = len(text) - start
+	}
+	return utf16Character(text, line+1, end+1), true
+}
+
+// utf16Character converts col, a column of text counted in bytes and
+// starting at 1, into a LSP character, expressed in UTF-16 code units and
+// starting at 0.
+func utf16Character(text []byte, line, col int) int {
+	return go2def.Column(text, line, col, go2def.ColumnUTF16) - 1
+}
+
+func (srv *lspServer) definitionLocation(descr go2def.Description) interface{} {
+	for _, info := range descr {
+		if info.Kind == go2def.InfoPos {
+			return srv.location(info.Position)
+		}
+	}
+	return nil
+}
+
+func (srv *lspServer) typeDefinitionLocation(descr go2def.Description) interface{} {
+	for _, info := range descr {
+		if info.Kind == go2def.InfoType && info.Position.IsValid() && strings.HasPrefix(info.Text, "type:") {
+			return srv.location(info.Position)
+		}
+	}
+	for _, info := range descr {
+		if info.Kind == go2def.InfoType && info.Position.IsValid() {
+			return srv.location(info.Position)
+		}
+	}
+	return nil
+}
+
+// hover returns the hover for descr, or nil if descr is an error.
+func hover(descr go2def.Description) interface{} {
+	var buf, doc strings.Builder
+	for _, info := range descr {
+		switch info.Kind {
+		case go2def.InfoErr:
+			return nil
+		case go2def.InfoObject, go2def.InfoSelection, go2def.InfoFunction, go2def.InfoType, go2def.InfoValue, go2def.InfoField, go2def.InfoGeneric, go2def.InfoPackage, go2def.InfoExpr:
+			buf.WriteString(info.Text)
+			buf.WriteString("\n")
+		case go2def.InfoTypeContents:
+			buf.WriteString(info.Text)
+		case go2def.InfoDoc:
+			doc.WriteString(info.Text)
+		}
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	var h lspHover
+	h.Contents.Kind = "markdown"
+	h.Contents.Value = "```go\n" + strings.TrimSpace(buf.String()) + "\n```"
+	if doc.Len() > 0 {
+		h.Contents.Value += "\n\n" + doc.String()
+	}
+	return &h
+}
+
+// location converts pos into a LSP location, converting its column into
+// UTF-16 code units.
+func (srv *lspServer) location(pos token.Position) interface{} {
+	if !pos.IsValid() {
+		return nil
+	}
+	p := lspPosition{Line: pos.Line - 1}
+	if pos.Column > 0 {
+		text, ok := srv.docs[pos.Filename]
+		if !ok {
+			text, _ = ioutil.ReadFile(pos.Filename)
+		}
+		p.Character = utf16Character(text, pos.Line, pos.Column)
+	}
+	return &lspLocation{URI: pathToURI(pos.Filename), Range: lspRange{Start: p, End: p}}
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
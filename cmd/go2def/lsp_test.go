@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aarzilli/go2def"
+)
+
+func TestLSPFraming(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		methods []string
+		err     bool
+	}{
+		{"Content-Length: 16\r\n\r\n{\"method\":\"foo\"}", []string{"foo"}, false},
+		{"Content-Length: 16\r\n\r\n{\"method\":\"foo\"}Content-Length: 16\r\n\r\n{\"method\":\"bar\"}", []string{"foo", "bar"}, false},
+		{"content-length: 16\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n{\"method\":\"foo\"}", []string{"foo"}, false},
+		{"Content-Type: application/vscode-jsonrpc\r\n\r\n{\"method\":\"foo\"}", nil, true},
+		{"Content-Length 16\r\n\r\n{\"method\":\"foo\"}", nil, true},
+		{"Content-Length: x\r\n\r\n{\"method\":\"foo\"}", nil, true},
+		{"Content-Length: 17\r\n\r\n{\"method\":\"foo\"}", nil, true},
+		{"Content-Length: 5\r\n\r\nhello", nil, true},
+	} {
+		srv := &lspServer{rd: bufio.NewReader(strings.NewReader(tc.in))}
+		for _, method := range tc.methods {
+			req, err := srv.read()
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", tc.in, err)
+				break
+			}
+			if req.Method != method {
+				t.Errorf("%q: wrong method %q, expected %q", tc.in, req.Method, method)
+			}
+		}
+		if tc.err {
+			if _, err := srv.read(); err == nil {
+				t.Errorf("%q: expected error", tc.in)
+			}
+		}
+	}
+
+	id := json.RawMessage("1")
+	for _, tc := range []struct {
+		result interface{}
+		rerr   *lspError
+		exp    string
+	}{
+		{"é", nil, "Content-Length: 38\r\n\r\n{\"jsonrpc\":\"2.0\",\"id\":1,\"result\":\"é\"}"},
+		{nil, nil, "Content-Length: 38\r\n\r\n{\"jsonrpc\":\"2.0\",\"id\":1,\"result\":null}"},
+		{nil, &lspError{Code: lspMethodNotFound, Message: "x"}, "Content-Length: 62\r\n\r\n{\"jsonrpc\":\"2.0\",\"id\":1,\"error\":{\"code\":-32601,\"message\":\"x\"}}"},
+	} {
+		var buf bytes.Buffer
+		srv := &lspServer{out: &buf}
+		srv.respond(&id, tc.result, tc.rerr)
+		if buf.String() != tc.exp {
+			t.Errorf("wrong response:\n\texp\t%q\n\tgot\t%q", tc.exp, buf.String())
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	if h := hover(go2def.Description{{Kind: go2def.InfoErr, Text: "unknown identifier x"}}); h != nil {
+		t.Errorf("hover for an error: %#v", h)
+	}
+	h, _ := hover(go2def.Description{{Kind: go2def.InfoType, Text: "type: int"}}).(*lspHover)
+	if h == nil || h.Contents.Value != "```go\ntype: int\n```" {
+		t.Errorf("wrong hover %#v", h)
+	}
+}
+
+func TestUTF16Offset(t *testing.T) {
+	text := []byte("a\U0001F600b\nxé\U0001F600y\n")
+	for _, tc := range []struct {
+		pos lspPosition
+		off int
+		ok  bool
+	}{
+		{lspPosition{0, 0}, 0, true},
+		{lspPosition{0, 1}, 1, true},
+		{lspPosition{0, 3}, 5, true}, // after a surrogate pair
+		{lspPosition{0, 4}, 6, true},
+		{lspPosition{1, 1}, 8, true},
+		{lspPosition{1, 2}, 10, true},
+		{lspPosition{1, 4}, 14, true},
+		{lspPosition{1, 5}, 15, true},
+		{lspPosition{2, 0}, 16, true},
+		{lspPosition{3, 0}, 0, false},
+		{lspPosition{0, 5}, 6, true}, // past the end of the line
+		{lspPosition{1, 100}, 15, true},
+		{lspPosition{2, 3}, 16, true},
+		{lspPosition{3, 1}, 0, false},
+	} {
+		off, ok := utf16Offset(text, tc.pos)
+		if ok != tc.ok || (ok && off != tc.off) {
+			t.Errorf("%v: got %d %v, expected %d %v", tc.pos, off, ok, tc.off, tc.ok)
+		}
+	}
+
+	for _, tc := range []struct {
+		line, col int
+		character int
+	}{
+		{1, 1, 0},
+		{1, 6, 3},
+		{2, 1, 0},
+		{2, 2, 1},
+		{2, 4, 2},
+		{2, 8, 4},
+	} {
+		if character := utf16Character(text, tc.line, tc.col); character != tc.character {
+			t.Errorf("%d:%d: got %d, expected %d", tc.line, tc.col, character, tc.character)
+		}
+	}
+}
+
+func TestURIToPath(t *testing.T) {
+	for _, tc := range []struct {
+		uri, path string
+	}{
+		{"file:///home/user/a.go", "/home/user/a.go"},
+		{"file:///home/user/a%20b.go", "/home/user/a b.go"},
+		{"file:///home/user/%C3%A9.go", "/home/user/é.go"},
+		{"untitled:Untitled-1", "untitled:Untitled-1"},
+	} {
+		if path := uriToPath(tc.uri); path != tc.path {
+			t.Errorf("%q: got %q, expected %q", tc.uri, path, tc.path)
+		}
+		if strings.HasPrefix(tc.uri, "file:") {
+			if uri := pathToURI(tc.path); uriToPath(uri) != tc.path {
+				t.Errorf("%q: round trip through %q failed", tc.path, uri)
+			}
+		}
+	}
+}
+
+func TestLSPModified(t *testing.T) {
+	tmpdir, err := ioutil.TempDir(os.TempDir(), "go2def-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	same, changed, unsaved := filepath.Join(tmpdir, "same.go"), filepath.Join(tmpdir, "changed.go"), filepath.Join(tmpdir, "unsaved.go")
+	ioutil.WriteFile(same, []byte("package p\n"), 0666)
+	ioutil.WriteFile(changed, []byte("package p\n"), 0666)
+
+	srv := &lspServer{docs: map[string][]byte{
+		same:    []byte("package p\n"),
+		changed: []byte("package p\n\nvar x int\n"),
+		unsaved: []byte("package p\n"),
+	}}
+	modfiles := srv.modified()
+	if len(modfiles) != 2 || modfiles[changed] == nil || modfiles[unsaved] == nil {
+		t.Errorf("wrong modified files %v", modfiles)
+	}
+
+	delete(srv.docs, changed)
+	delete(srv.docs, unsaved)
+	if modfiles := srv.modified(); modfiles != nil {
+		t.Errorf("wrong modified files %v", modfiles)
+	}
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -18,44 +21,135 @@ const verbose = false
 func usage() {
 	fmt.Printf("usage:\n")
 	fmt.Printf("\tgo2def daemon\n")
-	fmt.Printf("\t\tstarts go2def daemon\n")
-	fmt.Printf("\tgo2def describe [-modified] <filename>:#<startpos>[,#<endpos>]\n")
+	fmt.Printf("\t\tstarts go2def daemon, while the daemon is running queries are sent to it\n")
+	fmt.Printf("\tgo2def describe [-modified] [-json] [-layout] [-path] [-record <out.tar>] [-cols byte|rune|utf16] <filename>:#<startpos>[,#<endpos>]\n")
+	fmt.Printf("\tgo2def describe [-modified] [-json] [-layout] [-path] [-record <out.tar>] [-cols byte|rune|utf16] <filename>:<line>:<col>[-<line>:<col>]\n")
 	fmt.Printf("\t\tdescribes the specified selection, if -modified is specified it reads an archive of modified files from standard input\n")
+	fmt.Printf("\t\tlines and columns start at 1, -cols specifies whether columns count bytes (default), unicode code points or UTF-16 code units\n")
+	fmt.Printf("\t\tif -json is specified the description is written as JSON\n")
+	fmt.Printf("\t\tif -layout is specified the memory layout of structs is described, including padding between fields\n")
+	fmt.Printf("\t\tif -path is specified the syntax nodes enclosing the selection are listed, with their positions\n")
+	fmt.Printf("\t\tif -record is specified all the inputs of the query are saved to out.tar\n")
+	fmt.Printf("\tgo2def refs [-modified] [-json] [-cols byte|rune|utf16] <position>\n")
+	fmt.Printf("\t\tlists all references to the object at the specified position, in all packages of its module\n")
+	fmt.Printf("\t\tthe position is specified as in describe\n")
+	fmt.Printf("\tgo2def implements [-modified] [-json] [-stdlib] [-cols byte|rune|utf16] <position>\n")
+	fmt.Printf("\t\tlists the types implementing the interface at the specified position, or the interfaces implemented by the type\n")
+	fmt.Printf("\t\tif -stdlib is specified types of the standard library are also considered\n")
+	fmt.Printf("\tgo2def callers [-modified] [-json] [-depth N] [-cols byte|rune|utf16] <position>\n")
+	fmt.Printf("\t\tlists all calls to the function at the specified position, with the function containing each call\n")
+	fmt.Printf("\tgo2def callees [-modified] [-json] [-depth N] [-cols byte|rune|utf16] <position>\n")
+	fmt.Printf("\t\tlists the functions statically called by the function at the specified position\n")
+	fmt.Printf("\t\tif -depth is specified the call tree is written up to N levels deep\n")
+	fmt.Printf("\tgo2def rename [-modified] [-w|-diff|-json] [-cols byte|rune|utf16] <position> <newname>\n")
+	fmt.Printf("\t\trenames the object at the specified position in all packages of its module\n")
+	fmt.Printf("\t\twrites a unified diff of the changes (-diff, default), the list of edits as JSON (-json) or changes the files in place (-w)\n")
+	fmt.Printf("\tgo2def lsp\n")
+	fmt.Printf("\t\truns a language server on standard input and output\n")
+	fmt.Printf("\tgo2def replay <out.tar>\n")
+	fmt.Printf("\t\tre-executes a query saved with describe -record\n")
 	fmt.Printf("\tgo2def quit\n")
 	fmt.Printf("\t\tstops daemon\n")
 	os.Exit(1)
 }
 
 func main() {
+	if go2def.RunReplayDriver() {
+		return
+	}
+
 	if len(os.Args) < 2 {
 		fmt.Printf("not enough arguments\n")
 		usage()
 	}
 
 	switch os.Args[1] {
-	case "describe":
+	case "daemon":
+		daemon()
+	case "describe", "refs", "implements", "callers", "callees":
+		if remote(os.Args[1:], hasFlag(os.Args[2:], "-modified")) {
+			return
+		}
+		w := bufio.NewWriter(os.Stdout)
+		defer w.Flush()
+		query(w, bufio.NewReader(os.Stdin), "", os.Args[1], os.Args[2:], nil)
+	case "rename":
 		w := bufio.NewWriter(os.Stdout)
 		defer w.Flush()
-		describe(w, bufio.NewReader(os.Stdin), os.Args[2:])
+		query(w, bufio.NewReader(os.Stdin), "", os.Args[1], os.Args[2:], nil)
+	case "lsp":
+		lsp()
+	case "replay":
+		if len(os.Args) < 3 {
+			fmt.Printf("not enough arguments\n")
+			usage()
+		}
+		replay(os.Args[2])
+	case "quit":
+		if !remote(os.Args[1:], false) {
+			fmt.Printf("daemon not running\n")
+		}
 	default:
 		fmt.Printf("unknown command: %q\n", os.Args[1])
 
 	}
 }
 
-func describe(out io.Writer, rd *bufio.Reader, args []string) {
-	modified, path, pos, ok := parseDescribeArgs(out, args)
+func hasFlag(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// lineColRx matches positions in the form <filename>:<line>:<col>[-<line>:<col>]
+var lineColRx = regexp.MustCompile(`^(.*):(\d+):(\d+)(?:-(\d+):(\d+))?$`)
+
+// queries maps the name of each query command to the function executing it.
+var queries = map[string]func(path string, pos [2]int, cfg *go2def.Config) go2def.Description{
+	"describe":   go2def.Describe,
+	"refs":       go2def.References,
+	"implements": go2def.Implements,
+	"callers":    go2def.Callers,
+	"callees":    go2def.Callees,
+}
+
+// queryArgs are the arguments of a query command.
+type queryArgs struct {
+	modified bool              // read an archive of modified files from standard input
+	record   string            // save the inputs of the query to this file
+	json     bool              // write the description as JSON
+	cols     go2def.ColumnUnit // unit of columns in line:col positions
+	stdlib   bool              // include standard library types in implements
+	depth    int               // depth of the call tree of callers and callees
+	layout   bool              // describe the memory layout of structs
+	nodePath bool              // describe the nodes enclosing the selection
+	write    bool              // rename files in place
+	newName  string            // new name for rename
+	path     string
+	pos      [2]int
+
+	lineCol    bool      // position was specified as line:col
+	lineColPos [2][2]int // start and end line:col
+}
+
+// query executes the query command cmd.
+func query(out io.Writer, rd *bufio.Reader, wd, cmd string, args []string, cache *go2def.Cache) {
+	dargs, ok := parseQueryArgs(out, cmd, args)
 	if !ok {
 		return
 	}
+	dargs.path = absPath(wd, dargs.path)
 
 	if verbose {
-		log.Printf("describe modified=%v path=%q start=%d end=%d", modified, path, pos[0], pos[1])
+		log.Printf("%s modified=%v path=%q start=%d end=%d", cmd, dargs.modified, dargs.path, dargs.pos[0], dargs.pos[1])
 	}
 
 	var modfiles map[string][]byte
 
-	if modified {
+	if dargs.modified {
 		modbuf, err := rd.ReadBytes(0)
 		if err != nil {
 			log.Printf("error reading request: %v", err)
@@ -63,79 +157,226 @@ func describe(out io.Writer, rd *bufio.Reader, args []string) {
 		}
 		if len(modbuf) > 0 {
 			modbuf = modbuf[:len(modbuf)-1]
-			modfiles = parseModified(modbuf)
+			modfiles, err = parseModified(modbuf)
+			if err != nil {
+				fmt.Fprintf(out, "%v\n", err)
+				return
+			}
+		}
+	}
+
+	if dargs.lineCol {
+		for i := range dargs.pos {
+			var err error
+			dargs.pos[i], err = go2def.Offset(dargs.path, dargs.lineColPos[i][0], dargs.lineColPos[i][1], dargs.cols, modfiles)
+			if err != nil {
+				fmt.Fprintf(out, "could not convert position: %v\n", err)
+				return
+			}
+		}
+	}
+
+	cfg := &go2def.Config{Out: out, Modfiles: modfiles, Cache: cache, JSON: dargs.json, Stdlib: dargs.stdlib, Depth: dargs.depth, Layout: dargs.layout, Path: dargs.nodePath}
+	if dargs.record != "" {
+		cfg.Record = &go2def.Recording{}
+	}
+
+	if cmd == "rename" {
+		rename(out, &dargs, cfg)
+		return
+	}
+
+	queries[cmd](dargs.path, dargs.pos, cfg)
+
+	if cfg.Record != nil {
+		if err := saveRecording(absPath(wd, dargs.record), cfg.Record); err != nil {
+			fmt.Fprintf(out, "could not save recording: %v\n", err)
 		}
 	}
+}
 
-	go2def.Describe(path, pos, &go2def.Config{Out: out, Modfiles: modfiles})
+// absPath returns path relative to wd, or the current directory if wd is
+// empty.
+func absPath(wd, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	if wd != "" {
+		return filepath.Join(wd, path)
+	}
+	if abspath, err := filepath.Abs(path); err == nil {
+		return abspath
+	}
+	return path
 }
 
-func parseDescribeArgs(out io.Writer, argv []string) (modified bool, path string, pos [2]int, ok bool) {
+func parseQueryArgs(out io.Writer, cmd string, argv []string) (dargs queryArgs, ok bool) {
+	for len(argv) > 0 && strings.HasPrefix(argv[0], "-") {
+		switch argv[0] {
+		case "-modified":
+			dargs.modified = true
+		case "-json":
+			dargs.json = true
+		case "-stdlib":
+			if cmd != "implements" {
+				fmt.Fprintf(out, "-stdlib is only supported by implements")
+				return
+			}
+			dargs.stdlib = true
+		case "-layout":
+			if cmd != "describe" {
+				fmt.Fprintf(out, "-layout is only supported by describe")
+				return
+			}
+			dargs.layout = true
+		case "-path":
+			if cmd != "describe" {
+				fmt.Fprintf(out, "-path is only supported by describe")
+				return
+			}
+			dargs.nodePath = true
+		case "-w", "-diff":
+			if cmd != "rename" {
+				fmt.Fprintf(out, "%s is only supported by rename", argv[0])
+				return
+			}
+			dargs.write = argv[0] == "-w"
+		case "-depth":
+			if cmd != "callers" && cmd != "callees" {
+				fmt.Fprintf(out, "-depth is only supported by callers and callees")
+				return
+			}
+			if len(argv) < 2 {
+				fmt.Fprintf(out, "-depth requires an argument")
+				return
+			}
+			argv = argv[1:]
+			var err error
+			dargs.depth, err = strconv.Atoi(argv[0])
+			if err != nil || dargs.depth < 1 {
+				fmt.Fprintf(out, "invalid depth %q", argv[0])
+				return
+			}
+		case "-cols":
+			if len(argv) < 2 {
+				fmt.Fprintf(out, "-cols requires an argument")
+				return
+			}
+			argv = argv[1:]
+			var err error
+			dargs.cols, err = go2def.ParseColumnUnit(argv[0])
+			if err != nil {
+				fmt.Fprintf(out, "%v", err)
+				return
+			}
+		case "-record":
+			if cmd != "describe" {
+				fmt.Fprintf(out, "-record is only supported by describe")
+				return
+			}
+			if len(argv) < 2 {
+				fmt.Fprintf(out, "-record requires an argument")
+				return
+			}
+			argv = argv[1:]
+			dargs.record = argv[0]
+		default:
+			fmt.Fprintf(out, "unknown %s flag %q", cmd, argv[0])
+			return
+		}
+		argv = argv[1:]
+	}
+
 	if len(argv) <= 0 {
-		fmt.Fprintf(out, "could not parse describe argument %q", argv)
+		fmt.Fprintf(out, "could not parse position argument %q", argv)
 		return
 	}
 
+	if cmd == "rename" {
+		if len(argv) != 2 {
+			fmt.Fprintf(out, "rename requires a position and a new name")
+			return
+		}
+		if dargs.write && dargs.modified {
+			fmt.Fprintf(out, "-w can not be used with -modified")
+			return
+		}
+		dargs.newName = argv[1]
+	}
+
 	args := argv[0]
 
-	if argv[0] == "-modified" {
-		modified = true
-		args = argv[1]
+	if m := lineColRx.FindStringSubmatch(args); m != nil {
+		dargs.path = m[1]
+		dargs.lineCol = true
+		for i := range dargs.lineColPos {
+			if m[2*i+2] == "" {
+				dargs.lineColPos[i] = dargs.lineColPos[0]
+				continue
+			}
+			for j := range dargs.lineColPos[i] {
+				dargs.lineColPos[i][j], _ = strconv.Atoi(m[2*i+2+j])
+			}
+		}
+		ok = true
+		return
 	}
 
 	colon := strings.LastIndex(args, ":")
 	if colon < 0 {
-		fmt.Fprintf(out, "could not parse describe argument %q", args)
+		fmt.Fprintf(out, "could not parse position argument %q", args)
 		return
 	}
-	path = args[:colon]
+	dargs.path = args[:colon]
 	v := strings.SplitN(args[colon+1:], ",", 2)
 	for i := range v {
 		if len(v[i]) < 2 || v[i][0] != '#' {
-			fmt.Fprintf(out, "could not parse describe argument %q", args)
+			fmt.Fprintf(out, "could not parse position argument %q", args)
 			return
 		}
 		var err error
-		pos[i], err = strconv.Atoi(v[i][1:])
+		dargs.pos[i], err = strconv.Atoi(v[i][1:])
 		if err != nil {
-			fmt.Fprintf(out, "could not parse describe argument %q: %v", args, err)
+			fmt.Fprintf(out, "could not parse position argument %q: %v", args, err)
 			return
 		}
 	}
 	if len(v) == 1 {
-		pos[1] = pos[0]
+		dargs.pos[1] = dargs.pos[0]
 	}
 
 	ok = true
 	return
 }
 
-func parseModified(buf []byte) map[string][]byte {
+// parseModified parses an archive of modified files: for each file its
+// name and its size, each on a line, followed by its contents.
+func parseModified(buf []byte) (map[string][]byte, error) {
 	r := map[string][]byte{}
 	for len(buf) > 0 {
 		nl := bytes.Index(buf, []byte{'\n'})
 		if nl < 0 {
-			log.Fatalf("error parsing modified input")
-			return nil
+			return nil, errors.New("error parsing modified input: missing file name")
 		}
 		filename := string(buf[:nl])
 		buf = buf[nl+1:]
 
 		nl = bytes.Index(buf, []byte{'\n'})
 		if nl < 0 {
-			log.Fatalf("error parsing modified input")
-			return nil
+			return nil, fmt.Errorf("error parsing modified input: missing size of %s", filename)
 		}
 		szstr := string(buf[:nl])
 		buf = buf[nl+1:]
 
 		sz, err := strconv.Atoi(szstr)
 		if err != nil {
-			log.Fatalf("error parsing modified input: %v", err)
-			return nil
+			return nil, fmt.Errorf("error parsing modified input: %v", err)
+		}
+		if sz < 0 || sz > len(buf) {
+			return nil, fmt.Errorf("error parsing modified input: wrong size %d of %s", sz, filename)
 		}
 		r[filename] = buf[:sz]
 		buf = buf[sz:]
 	}
-	return r
+	return r, nil
 }
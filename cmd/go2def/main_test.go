@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseModified(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		files map[string]string
+		err   bool
+	}{
+		{"", map[string]string{}, false},
+		{"a.go\n3\nabcb.go\n0\n", map[string]string{"a.go": "abc", "b.go": ""}, false},
+		{"a.go", nil, true},
+		{"a.go\n3", nil, true},
+		{"a.go\nthree\nabc", nil, true},
+		{"a.go\n4\nabc", nil, true},
+		{"a.go\n-1\nabc", nil, true},
+	} {
+		files, err := parseModified([]byte(tc.in))
+		if (err != nil) != tc.err {
+			t.Errorf("%q: unexpected error %v", tc.in, err)
+			continue
+		}
+		if len(files) != len(tc.files) {
+			t.Errorf("%q: wrong number of files %d", tc.in, len(files))
+		}
+		for name, buf := range tc.files {
+			if string(files[name]) != buf {
+				t.Errorf("%q: wrong contents of %s: %q", tc.in, name, files[name])
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownedByUser returns true if the file described by fi belongs to the
+// current user.
+func ownedByUser(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
+
+// privateToUser returns true if the file described by fi belongs to the
+// current user and can not be accessed by other users.
+func privateToUser(fi os.FileInfo) bool {
+	return ownedByUser(fi) && fi.Mode().Perm()&0077 == 0
+}
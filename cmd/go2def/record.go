@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/aarzilli/go2def"
+)
+
+func saveRecording(path string, rec *go2def.Recording) error {
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := rec.Save(fh); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// replay re-executes the query saved in the recording at path.
+func replay(path string) {
+	fh, err := os.Open(path)
+	if err != nil {
+		fmt.Printf("could not open recording: %v\n", err)
+		os.Exit(1)
+	}
+	defer fh.Close()
+
+	dir, err := ioutil.TempDir("", "go2def-replay-")
+	if err != nil {
+		fmt.Printf("could not create temporary directory: %v\n", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(dir)
+
+	rec, err := go2def.ExtractRecording(fh, dir)
+	if err != nil {
+		fmt.Printf("could not read recording: %v\n", err)
+		return
+	}
+
+	if verbose {
+		fmt.Printf("replaying %s:#%d,#%d GOOS=%s GOARCH=%s tags=%q\n", rec.Path, rec.Pos[0], rec.Pos[1], rec.Goos, rec.Goarch, rec.BuildTags)
+	}
+
+	go2def.Replay(rec, &go2def.Config{Out: os.Stdout})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/aarzilli/go2def"
+)
+
+// rename executes the rename command, it writes a unified diff of the
+// changes, the list of edits as JSON or, if -w was specified, changes the
+// files in place.
+func rename(out io.Writer, dargs *queryArgs, cfg *go2def.Config) {
+	edits, err := go2def.Rename(dargs.path, dargs.pos, dargs.newName, cfg)
+	if err != nil {
+		fmt.Fprintf(out, "%v\n", err)
+		return
+	}
+
+	if dargs.json {
+		buf, err := json.MarshalIndent(edits, "", "\t")
+		if err != nil {
+			fmt.Fprintf(out, "%v\n", err)
+			return
+		}
+		out.Write(buf)
+		out.Write([]byte("\n"))
+		return
+	}
+
+	for len(edits) > 0 {
+		filename := edits[0].Filename
+		n := 1
+		for n < len(edits) && edits[n].Filename == filename {
+			n++
+		}
+		fileEdits := edits[:n]
+		edits = edits[n:]
+
+		old, ok := cfg.Modfiles[filename]
+		if !ok {
+			old, err = ioutil.ReadFile(filename)
+			if err != nil {
+				fmt.Fprintf(out, "could not read %s: %v\n", filename, err)
+				return
+			}
+		}
+		new := go2def.ApplyEdits(old, fileEdits)
+
+		if !dargs.write {
+			io.WriteString(out, unifiedDiff(filename, old, new))
+			continue
+		}
+		fi, err := os.Stat(filename)
+		if err != nil {
+			fmt.Fprintf(out, "could not write %s: %v\n", filename, err)
+			return
+		}
+		if err := ioutil.WriteFile(filename, new, fi.Mode()); err != nil {
+			fmt.Fprintf(out, "could not write %s: %v\n", filename, err)
+			return
+		}
+		fmt.Fprintf(out, "%s: %d occurrences renamed\n", filename, len(fileEdits))
+	}
+}
+
+// unifiedDiff returns a unified diff between old and new, which must have
+// the same number of lines, as is the case for the edits returned by
+// go2def.Rename.
+func unifiedDiff(filename string, old, new []byte) string {
+	const context = 3
+
+	a, b := splitLines(old), splitLines(new)
+	if len(a) != len(b) {
+		panic("unifiedDiff: different number of lines")
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", filename, filename)
+
+	for i := 0; i < len(a); {
+		if a[i] == b[i] {
+			i++
+			continue
+		}
+
+		// the hunk ends when there are no changes for 2*context lines
+		end := i + 1
+		for j := end; j < len(a) && j-end < 2*context; j++ {
+			if a[j] != b[j] {
+				end = j + 1
+			}
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		stop := end + context
+		if stop > len(a) {
+			stop = len(a)
+		}
+
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", start+1, stop-start, start+1, stop-start)
+		for k := start; k < stop; {
+			if a[k] == b[k] {
+				writeDiffLine(&buf, ' ', a[k])
+				k++
+				continue
+			}
+			m := k
+			for m < stop && a[m] != b[m] {
+				m++
+			}
+			for _, line := range a[k:m] {
+				writeDiffLine(&buf, '-', line)
+			}
+			for _, line := range b[k:m] {
+				writeDiffLine(&buf, '+', line)
+			}
+			k = m
+		}
+
+		i = stop
+	}
+
+	return buf.String()
+}
+
+// splitLines splits buf into lines, each line keeps its newline character.
+func splitLines(buf []byte) []string {
+	lines := strings.SplitAfter(string(buf), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func writeDiffLine(buf *strings.Builder, prefix byte, line string) {
+	buf.WriteByte(prefix)
+	buf.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		buf.WriteString("\n\\ No newline at end of file\n")
+	}
+}
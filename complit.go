@@ -0,0 +1,27 @@
+package go2def
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// describeElidedType adds the type of lit to the description, with the
+// position of its declaration, if the type was elided from the literal.
+func describeElidedType(ctx *context, pkg *packages.Package, lit *ast.CompositeLit) {
+	if lit.Type != nil {
+		return
+	}
+	typ := pkg.TypesInfo.Types[lit].Type
+	if typ == nil {
+		return
+	}
+	ctx.out.expr("elided type: " + printTypesTypeNice(typ))
+	if ptyp, isptr := typ.(*types.Pointer); isptr {
+		typ = ptyp.Elem()
+	}
+	if named, isnamed := typ.(*types.Named); isnamed && named.Obj().Pkg() != nil {
+		ctx.out.pos(ctx.position(named.Obj().Pos()))
+	}
+}
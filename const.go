@@ -0,0 +1,58 @@
+package go2def
+
+import (
+	"bytes"
+	"fmt"
+	"go/constant"
+	"go/types"
+	"sort"
+)
+
+// describeConst adds the value of c to the description and, if the type of c
+// is a named type, all the constants of the same type declared in its
+// package.
+func describeConst(ctx *context, c *types.Const) {
+	ctx.out.value(constantString(c.Val()))
+
+	named, isnamed := c.Type().(*types.Named)
+	if !isnamed || c.Pkg() == nil || c.Parent() != c.Pkg().Scope() {
+		return
+	}
+
+	consts := []*types.Const{}
+	scope := c.Pkg().Scope()
+	for _, name := range scope.Names() {
+		if c2, isconst := scope.Lookup(name).(*types.Const); isconst && types.Identical(c2.Type(), named) {
+			consts = append(consts, c2)
+		}
+	}
+	// sort in declaration order
+	sort.Slice(consts, func(i, j int) bool {
+		a, b := ctx.getPosition(consts[i].Pos()), ctx.getPosition(consts[j].Pos())
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+
+	out := new(bytes.Buffer)
+	fmt.Fprintf(out, "\nConstants:\n")
+	for _, c2 := range consts {
+		fmt.Fprintf(out, "\t%s = %s\n", c2.Name(), c2.Val().ExactString())
+	}
+	ctx.out.typeContents(out.String())
+}
+
+// constantString returns v formatted exactly, integers are also formatted in
+// hexadecimal and floats also as an approximate decimal number.
+func constantString(v constant.Value) string {
+	switch v.Kind() {
+	case constant.Int:
+		return fmt.Sprintf("%s (%#x)", v.ExactString(), constant.Val(v))
+	case constant.Float:
+		if s := v.String(); s != v.ExactString() {
+			return fmt.Sprintf("%s (%s)", v.ExactString(), s)
+		}
+	}
+	return v.ExactString()
+}
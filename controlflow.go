@@ -0,0 +1,154 @@
+package go2def
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// stmtPath returns the nodes enclosing stmt, from the root of its file to
+// stmt itself.
+func stmtPath(pkg *packages.Package, stmt ast.Stmt) []ast.Node {
+	file := fileOf(pkg, stmt)
+	if file == nil {
+		return nil
+	}
+	return pathEnclosing(file, stmt.Pos(), stmt.End())
+}
+
+// describeReturn adds the results of the function containing stmt to the
+// description, followed by the position of the function.
+func describeReturn(ctx *context, pkg *packages.Package, stmt *ast.ReturnStmt) {
+	path := stmtPath(pkg, stmt)
+	for i := len(path) - 1; i >= 0; i-- {
+		var sig *types.Signature
+		name := "function literal"
+		switch fn := path[i].(type) {
+		case *ast.FuncDecl:
+			if obj := pkg.TypesInfo.Defs[fn.Name]; obj != nil {
+				sig, _ = obj.Type().(*types.Signature)
+				name = "func " + fn.Name.Name
+			}
+		case *ast.FuncLit:
+			sig, _ = pkg.TypesInfo.Types[fn].Type.(*types.Signature)
+		default:
+			continue
+		}
+		if sig == nil {
+			return
+		}
+		ctx.out.expr("return from " + name)
+		describeType(ctx, "results:", sig.Results())
+		ctx.out.pos(ctx.position(path[i].Pos()))
+		return
+	}
+}
+
+// describeBranch adds the target of a break, continue, goto or fallthrough
+// statement to the description.
+func describeBranch(ctx *context, pkg *packages.Package, stmt *ast.BranchStmt) {
+	path := stmtPath(pkg, stmt)
+	var target ast.Node
+
+	if stmt.Label != nil {
+		label := pkg.TypesInfo.Uses[stmt.Label]
+		ast.Inspect(fileOf(pkg, stmt), func(node ast.Node) bool {
+			if ls, islabeled := node.(*ast.LabeledStmt); islabeled && label != nil && pkg.TypesInfo.Defs[ls.Label] == label {
+				target = ls
+				if stmt.Tok != token.GOTO {
+					target = ls.Stmt
+				}
+			}
+			return target == nil
+		})
+	} else {
+		target = branchTarget(stmt.Tok, path)
+	}
+
+	if target == nil {
+		return
+	}
+	ctx.out.expr(fmt.Sprintf("%s target: %s", stmt.Tok, stmtName(target)))
+	ctx.out.pos(ctx.position(target.Pos()))
+}
+
+// branchTarget returns the target of an unlabeled branch statement of kind
+// tok whose enclosing nodes are path.
+func branchTarget(tok token.Token, path []ast.Node) ast.Node {
+	for i := len(path) - 1; i >= 0; i-- {
+		switch node := path[i].(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return nil
+		case *ast.ForStmt, *ast.RangeStmt:
+			if tok == token.BREAK || tok == token.CONTINUE {
+				return node
+			}
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			if tok == token.BREAK {
+				return node
+			}
+		case *ast.CaseClause:
+			if tok != token.FALLTHROUGH || i < 2 {
+				continue
+			}
+			// the parent of a case clause is the body of the switch
+			body, isblock := path[i-1].(*ast.BlockStmt)
+			if !isblock {
+				return nil
+			}
+			for j := range body.List {
+				if body.List[j] == node && j+1 < len(body.List) {
+					return body.List[j+1]
+				}
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// stmtName returns a short description of the kind of statement node is.
+func stmtName(node ast.Node) string {
+	switch node := node.(type) {
+	case *ast.ForStmt:
+		return "for loop"
+	case *ast.RangeStmt:
+		return "range loop"
+	case *ast.SwitchStmt:
+		return "switch"
+	case *ast.TypeSwitchStmt:
+		return "type switch"
+	case *ast.SelectStmt:
+		return "select"
+	case *ast.LabeledStmt:
+		return "label " + node.Label.Name
+	case *ast.CaseClause:
+		if node.List == nil {
+			return "default case"
+		}
+		return "next case"
+	}
+	return "statement"
+}
+
+// describeDeferredCall describes the call of a defer or go statement,
+// followed by the position of the called function.
+func describeDeferredCall(ctx *context, pkg *packages.Package, tok token.Token, call *ast.CallExpr) {
+	switch tok {
+	case token.DEFER:
+		ctx.out.expr("deferred call, runs when the function returns")
+	case token.GO:
+		ctx.out.expr("call executed in a new goroutine")
+	}
+	describeType(ctx, "type:", pkg.TypesInfo.Types[call].Type)
+	describeCallKind(ctx, pkg, call)
+
+	if lit, islit := unparen(call.Fun).(*ast.FuncLit); islit {
+		ctx.out.pos(ctx.position(lit.Pos()))
+	} else if fn, _ := staticCallee(pkg, call); fn != nil && fn.Pkg() != nil {
+		ctx.out.pos(ctx.position(fn.Pos()))
+	}
+}
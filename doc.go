@@ -0,0 +1,51 @@
+package go2def
+
+import (
+	"go/ast"
+)
+
+// describeDoc adds the documentation of the declaration decl to the
+// description.
+func describeDoc(ctx *context, decl *declaration) {
+	if doc := declDoc(decl); doc != "" {
+		ctx.out.doc(doc)
+	}
+}
+
+// declDoc returns the documentation of the declaration decl, taken from the
+// doc comment or the line comment of the spec, field or function declaring
+// it. If the spec has no comments the doc comment of the enclosing
+// declaration is used.
+func declDoc(decl *declaration) string {
+	if decl == nil {
+		return ""
+	}
+	var gendecl *ast.GenDecl
+	if len(decl.path) >= 2 {
+		gendecl, _ = decl.path[len(decl.path)-2].(*ast.GenDecl)
+	}
+	var doc *ast.CommentGroup
+	switch node := decl.node.(type) {
+	case *ast.FuncDecl:
+		doc = node.Doc
+	case *ast.TypeSpec:
+		doc = specDoc(node.Doc, node.Comment, gendecl)
+	case *ast.ValueSpec:
+		doc = specDoc(node.Doc, node.Comment, gendecl)
+	case *ast.Field:
+		doc = specDoc(node.Doc, node.Comment, nil)
+	}
+	return doc.Text()
+}
+
+func specDoc(doc, comment *ast.CommentGroup, gendecl *ast.GenDecl) *ast.CommentGroup {
+	switch {
+	case doc != nil:
+		return doc
+	case comment != nil:
+		return comment
+	case gendecl != nil:
+		return gendecl.Doc
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package go2def
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// findEnclosingNode returns the innermost node of root that encloses the
+// selection and can be described. Spaces around the selection and
+// unbalanced parenthesis at its start or end are ignored.
+func findEnclosingNode(ctx *context, pkg *packages.Package, root *ast.File, filename string, pos [2]int) ast.Node {
+	tf := pkg.Fset.File(root.Pos())
+	if tf == nil {
+		return nil
+	}
+	buf := ctx.source(filename)
+	if buf == nil || len(buf) != tf.Size() {
+		return nil
+	}
+	start, end := trimSelection(buf, pos[0], pos[1])
+	if start < 0 || end > tf.Size() || start > end {
+		return nil
+	}
+
+	path := pathEnclosing(root, tf.Pos(start), tf.Pos(end))
+	for i := len(path) - 1; i >= 0; i-- {
+		if describable(pkg, path[i]) {
+			return path[i]
+		}
+	}
+	return nil
+}
+
+// trimSelection removes spaces and unbalanced parenthesis from the start
+// and the end of the selection of buf between start and end.
+func trimSelection(buf []byte, start, end int) (int, int) {
+	if start < 0 || end > len(buf) || start > end {
+		return start, end
+	}
+	isSpace := func(ch byte) bool {
+		return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+	}
+	for {
+		for start < end && isSpace(buf[start]) {
+			start++
+		}
+		for end > start && isSpace(buf[end-1]) {
+			end--
+		}
+		sel := string(buf[start:end])
+		opening, closing := strings.Count(sel, "("), strings.Count(sel, ")")
+		switch {
+		case opening > closing && buf[start] == '(':
+			start++
+		case closing > opening && buf[end-1] == ')':
+			end--
+		default:
+			return start, end
+		}
+	}
+}
+
+// pathEnclosing returns the nodes of root that contain the interval between
+// start and end, from the outermost to the innermost. When an empty interval
+// touches two nodes the first one is chosen.
+func pathEnclosing(root *ast.File, start, end token.Pos) []ast.Node {
+	st := &enclosingState{start: start, end: end}
+	ast.Walk(enclosingVisitor{st, 0}, root)
+	return st.path
+}
+
+type enclosingState struct {
+	start, end token.Pos
+	path       []ast.Node
+}
+
+type enclosingVisitor struct {
+	st    *enclosingState
+	depth int
+}
+
+func (v enclosingVisitor) Visit(node ast.Node) ast.Visitor {
+	if node == nil || node.Pos() > v.st.start || node.End() < v.st.end || len(v.st.path) != v.depth {
+		return nil
+	}
+	v.st.path = append(v.st.path, node)
+	return enclosingVisitor{v.st, v.depth + 1}
+}
+
+// describable returns true if describeNode can describe node.
+func describable(pkg *packages.Package, node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.BasicLit, *ast.ImportSpec, *ast.TypeSwitchStmt:
+		return true
+	case *ast.ReturnStmt, *ast.BranchStmt, *ast.DeferStmt, *ast.GoStmt:
+		return true
+	case ast.Expr:
+		_, ok := pkg.TypesInfo.Types[node]
+		return ok
+	}
+	return false
+}
+
+// describePath adds the nodes enclosing node to the description, from node
+// to the root of its file, each one with the selection that would select it.
+func describePath(ctx *context, pkg *packages.Package, node ast.Node) {
+	file := fileOf(pkg, node)
+	if file == nil {
+		return
+	}
+	path := pathEnclosing(file, node.Pos(), node.End())
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == node {
+			path = path[:i+1]
+			break
+		}
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		start, end := ctx.position(path[i].Pos()), ctx.position(path[i].End())
+		name := strings.TrimPrefix(fmt.Sprintf("%T", path[i]), "*ast.")
+		ctx.out.path(fmt.Sprintf("%s #%d,#%d", name, start.Offset, end.Offset), start, len(path)-1-i)
+	}
+}
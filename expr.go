@@ -0,0 +1,100 @@
+package go2def
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// describeExpr adds to the description what the type checker knows about
+// expr: whether it is a value, a type, a builtin or a void call, its
+// constant value, whether it is addressable or assignable and, for calls,
+// whether they are conversions, builtin calls or function calls.
+func describeExpr(ctx *context, pkg *packages.Package, expr ast.Expr) {
+	tv, ok := pkg.TypesInfo.Types[expr]
+	if !ok {
+		return
+	}
+
+	switch {
+	case tv.IsVoid():
+		ctx.out.expr("mode: void")
+	case tv.IsType():
+		ctx.out.expr("mode: type")
+	case tv.IsBuiltin():
+		ctx.out.expr("mode: builtin")
+	case tv.IsNil():
+		ctx.out.expr("mode: nil")
+	case tv.Value != nil:
+		ctx.out.expr("mode: constant")
+		ctx.out.value(constantString(tv.Value))
+		if bin, isbin := unparen(expr).(*ast.BinaryExpr); isbin {
+			describeFolding(ctx, pkg, bin)
+		}
+	case tv.IsValue():
+		ctx.out.expr("mode: value")
+		flags := []string{}
+		if tv.Addressable() {
+			flags = append(flags, "addressable")
+		}
+		if tv.Assignable() {
+			flags = append(flags, "assignable")
+		}
+		if tv.HasOk() {
+			flags = append(flags, "comma-ok")
+		}
+		if len(flags) > 0 {
+			ctx.out.expr(strings.Join(flags, ", "))
+		}
+	}
+
+	if call, iscall := unparen(expr).(*ast.CallExpr); iscall {
+		describeCallKind(ctx, pkg, call)
+	}
+}
+
+// describeFolding adds the values of the operands of a constant binary
+// expression to the description.
+func describeFolding(ctx *context, pkg *packages.Package, bin *ast.BinaryExpr) {
+	x, y := pkg.TypesInfo.Types[bin.X], pkg.TypesInfo.Types[bin.Y]
+	if x.Value == nil || y.Value == nil {
+		return
+	}
+	ctx.out.expr(fmt.Sprintf("folded: %s %s %s", x.Value.ExactString(), bin.Op, y.Value.ExactString()))
+}
+
+// describeCallKind adds to the description whether call is a conversion, a
+// call to a builtin function or a function call.
+func describeCallKind(ctx *context, pkg *packages.Package, call *ast.CallExpr) {
+	fun := pkg.TypesInfo.Types[call.Fun]
+	switch {
+	case fun.IsType():
+		ctx.out.expr(fmt.Sprintf("call: conversion to %s", printTypesTypeNice(fun.Type)))
+	case fun.IsBuiltin():
+		ctx.out.expr(fmt.Sprintf("call: builtin %s", printerSprint(ctx.getFileSet(call.Pos()), unparen(call.Fun))))
+	default:
+		switch fn, dynamic := staticCallee(pkg, call); {
+		case fn == nil:
+			ctx.out.expr("call: function value")
+		case dynamic:
+			ctx.out.expr(fmt.Sprintf("call: dynamic %s", funcName(fn)))
+		case fn.Type().(*types.Signature).Recv() != nil:
+			ctx.out.expr(fmt.Sprintf("call: method %s", funcName(fn)))
+		default:
+			ctx.out.expr(fmt.Sprintf("call: function %s", funcName(fn)))
+		}
+	}
+}
+
+func unparen(expr ast.Expr) ast.Expr {
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.X
+	}
+}
@@ -0,0 +1,164 @@
+package go2def
+
+import (
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aarzilli/go2def/visit"
+	"golang.org/x/tools/go/packages"
+)
+
+// Implements describes the implementation relation for the type at the
+// specified position, or the type of the object at the specified position.
+// If the type is an interface it lists all the named types of the loaded
+// workspace that implement it, otherwise it lists all the interfaces
+// implemented by the type or by a pointer to it.
+// Types declared in the standard library are only considered if
+// cfg.Stdlib is set.
+func Implements(path string, pos [2]int, cfg *Config) Description {
+	ctx := newContext(path, cfg)
+
+	_, obj, err := objectAt(ctx, path, pos, false)
+	if err != nil {
+		ctx.out.err("%v", err)
+		ctx.writeOut(true)
+		return ctx.out
+	}
+
+	typ := obj.Type()
+	if ptyp, isptr := typ.(*types.Pointer); isptr {
+		typ = ptyp.Elem()
+	}
+	named, isnamed := typ.(*types.Named)
+	if !isnamed {
+		ctx.out.err("%s is not a named type", printTypesTypeNice(typ))
+		ctx.writeOut(true)
+		return ctx.out
+	}
+
+	if iface, isiface := named.Underlying().(*types.Interface); isiface {
+		describeType(ctx, "interface:", named)
+		for _, t := range workspaceNamedTypes(ctx) {
+			if types.IsInterface(t) {
+				continue
+			}
+			switch {
+			case types.Implements(t, iface):
+				ctx.out.implements("implemented by "+printTypesTypeNice(t), ctx.position(t.Obj().Pos()))
+			case types.Implements(types.NewPointer(t), iface):
+				ctx.out.implements("implemented by *"+printTypesTypeNice(t), ctx.position(t.Obj().Pos()))
+			}
+		}
+	} else {
+		describeType(ctx, "type:", named)
+		ptr := types.NewPointer(named)
+		for _, t := range workspaceNamedTypes(ctx) {
+			iface, isiface := t.Underlying().(*types.Interface)
+			if !isiface || iface.NumMethods() == 0 || types.Identical(t, named) {
+				continue
+			}
+			switch {
+			case types.Implements(named, iface):
+				ctx.out.implements("implements "+printTypesTypeNice(t), ctx.position(t.Obj().Pos()))
+			case types.Implements(ptr, iface):
+				ctx.out.implements("implements "+printTypesTypeNice(t)+" (pointer receiver)", ctx.position(t.Obj().Pos()))
+			}
+		}
+	}
+
+	ctx.writeOut(true)
+	return ctx.out
+}
+
+// workspaceNamedTypes returns all the non-generic named types declared at
+// package scope in the workspace, sorted by package path and name. Types of
+// the standard library are only returned if ctx.Stdlib is set and never for
+// its internal packages.
+func workspaceNamedTypes(ctx *context) []*types.Named {
+	return visibleNamedTypes(ctx, nil)
+}
+
+// visibleNamedTypes is like workspaceNamedTypes but, if from is not nil,
+// only returns the types declared in from and in the packages that import
+// it, directly or indirectly.
+func visibleNamedTypes(ctx *context, from *types.Package) []*types.Named {
+	r := []*types.Named{}
+	seen := make(map[objKey]bool)
+	sees := make(map[*packages.Package]bool)
+	pkgit := visit.Packages(ctx.pkgs)
+	for pkgit.Next() {
+		pkg := pkgit.Pkg()
+		if pkg == nil || pkg.Types == nil {
+			continue
+		}
+		if from != nil && !importsPackage(pkg, from.Path(), sees) {
+			continue
+		}
+		if ctx.isStdlib(pkg) && (!ctx.Stdlib || isInternal(pkg.PkgPath)) {
+			continue
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			named, ok := tn.Type().(*types.Named)
+			if !ok || isGeneric(named) {
+				continue
+			}
+			if key := ctx.objKey(tn); !seen[key] {
+				seen[key] = true
+				r = append(r, named)
+			}
+		}
+	}
+	sort.Slice(r, func(i, j int) bool {
+		a, b := r[i].Obj(), r[j].Obj()
+		if a.Pkg().Path() != b.Pkg().Path() {
+			return a.Pkg().Path() < b.Pkg().Path()
+		}
+		return a.Name() < b.Name()
+	})
+	return r
+}
+
+// importsPackage returns true if pkg is path or imports it, directly or
+// indirectly. Results are memoized in sees.
+func importsPackage(pkg *packages.Package, path string, sees map[*packages.Package]bool) bool {
+	if r, ok := sees[pkg]; ok {
+		return r
+	}
+	sees[pkg] = false
+	r := pkg.PkgPath == path
+	for _, imp := range pkg.Imports {
+		if r {
+			break
+		}
+		r = importsPackage(imp, path, sees)
+	}
+	sees[pkg] = r
+	return r
+}
+
+// isStdlib returns true if pkg belongs to the standard library.
+func (ctx *context) isStdlib(pkg *packages.Package) bool {
+	goroot := ctx.Goroot()
+	if goroot == "" || len(pkg.GoFiles) == 0 {
+		return !strings.Contains(strings.SplitN(pkg.PkgPath, "/", 2)[0], ".")
+	}
+	return strings.HasPrefix(pkg.GoFiles[0], goroot+string(filepath.Separator))
+}
+
+// isInternal returns true if the package at path is an internal or vendored
+// package.
+func isInternal(path string) bool {
+	for _, elem := range strings.Split(path, "/") {
+		if elem == "internal" || elem == "vendor" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package go2def
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// describeImplicit describes identifiers that don't define or use an
+// object: the name of a package clause and the symbol of a type switch,
+// which declares a different implicit object in each case clause.
+// Returns false if id is neither.
+func describeImplicit(ctx *context, pkg *packages.Package, id *ast.Ident) bool {
+	file := fileOf(pkg, id)
+	if file == nil {
+		return false
+	}
+	if file.Name == id {
+		describePackage(ctx, pkg.Types, "", pkg.Fset.Position(file.Package))
+		return true
+	}
+
+	objs := typeSwitchObjects(pkg, file, id)
+	if objs == nil {
+		return false
+	}
+	for _, obj := range objs {
+		ctx.out.object(obj)
+	}
+	ctx.out.pos(ctx.position(id.Pos()))
+	return true
+}
+
+// fileOf returns the syntax tree of pkg containing node.
+func fileOf(pkg *packages.Package, node ast.Node) *ast.File {
+	for _, file := range pkg.Syntax {
+		if file.Pos() <= node.Pos() && node.Pos() < file.End() {
+			return file
+		}
+	}
+	return nil
+}
+
+// typeSwitchObjects returns the implicit objects declared in each case
+// clause of the type switch whose symbol is id.
+func typeSwitchObjects(pkg *packages.Package, file *ast.File, id *ast.Ident) []types.Object {
+	var objs []types.Object
+	ast.Inspect(file, func(node ast.Node) bool {
+		if objs != nil || node == nil || id.Pos() < node.Pos() || id.Pos() >= node.End() {
+			return false
+		}
+		sw, isswitch := node.(*ast.TypeSwitchStmt)
+		if !isswitch {
+			return true
+		}
+		assign, isassign := sw.Assign.(*ast.AssignStmt)
+		if !isassign || len(assign.Lhs) != 1 || assign.Lhs[0] != id {
+			return true
+		}
+		objs = []types.Object{}
+		for _, stmt := range sw.Body.List {
+			if obj := pkg.TypesInfo.Implicits[stmt]; obj != nil {
+				objs = append(objs, obj)
+			}
+		}
+		return false
+	})
+	return objs
+}
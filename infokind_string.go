@@ -4,9 +4,9 @@ package go2def
 
 import "strconv"
 
-const _InfoKind_name = "InfoErrInfoObjectInfoSelectionInfoFunctionInfoTypeInfoTypeContentsInfoPos"
+const _InfoKind_name = "InfoErrInfoObjectInfoSelectionInfoFunctionInfoTypeInfoTypeContentsInfoPosInfoRefInfoImplementsInfoCallInfoDocInfoValueInfoFieldInfoPromotionInfoGenericInfoPackageInfoExprInfoPath"
 
-var _InfoKind_index = [...]uint8{0, 7, 17, 30, 42, 50, 66, 73}
+var _InfoKind_index = [...]uint8{0, 7, 17, 30, 42, 50, 66, 73, 80, 94, 102, 109, 118, 127, 140, 151, 162, 170, 178}
 
 func (i InfoKind) String() string {
 	if i >= InfoKind(len(_InfoKind_index)-1) {
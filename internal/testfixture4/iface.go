@@ -0,0 +1,22 @@
+package testfixture4
+
+import "io"
+
+type /*a*/Shape/*b*/ interface {
+	Area() float64
+}
+
+type Square struct{ side float64 }
+
+func (s Square) Area() float64 { return s.side * s.side }
+
+type Circle struct{ r float64 }
+
+func (c *Circle) Area() float64 { return 3 * c.r * c.r }
+
+type /*c*/Buffer/*d*/ struct{}
+
+func (b *Buffer) Write(p []byte) (int, error) { return len(p), nil }
+func (b *Buffer) Area() float64               { return 0 }
+
+var _ io.Writer = &Buffer{}
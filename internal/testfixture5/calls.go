@@ -0,0 +1,30 @@
+package testfixture5
+
+import "strings"
+
+type Greeter interface {
+	Greet() string
+}
+
+type english struct{}
+
+func (english) Greet() string { return "hello" }
+
+func /*a*/top/*b*/() {
+	middle()
+	middle()
+}
+
+func /*c*/middle/*d*/() string {
+	var g Greeter = english{}
+	return strings.ToUpper(leaf(g))
+}
+
+func leaf(g Greeter) string {
+	if g == nil {
+		return leaf(english{})
+	}
+	return g.Greet()
+}
+
+var greeting = middle()
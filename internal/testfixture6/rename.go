@@ -0,0 +1,38 @@
+package testfixture6
+
+type Shape interface {
+	Area() int
+}
+
+type Square struct {
+	/*g*/side/*h*/ int
+	color string
+}
+
+func (s Square) /*a*/Area/*b*/() int { return s.side * s.side }
+
+func (s Square) Perimeter() int { return 4 * s.side }
+
+func /*c*/helper/*d*/(x int) int {
+	/*e*/total/*f*/ := 0
+	for i := 0; i < x; i++ {
+		total += i
+	}
+	return total
+}
+
+func use() int {
+	var sh Shape = Square{side: 2}
+	n := 3
+	return sh.Area() + helper(n)
+}
+
+func kind(x interface{}) int {
+	switch /*i*/v/*j*/ := x.(type) {
+	case int:
+		return /*k*/v/*l*/
+	case string:
+		return len(v)
+	}
+	return 0
+}
@@ -0,0 +1,24 @@
+package testfixture7
+
+type Weekday int
+
+const (
+	Sunday Weekday = iota
+	Monday
+	Tuesday
+)
+
+const Saturday Weekday = 6
+
+const (
+	Big      = 1 << 40
+	Greeting = "hello"
+	Third    = 1.0 / 3
+)
+
+func useConsts() {
+	_ = /*a*/Tuesday/*b*/
+	_ = /*c*/Big/*d*/
+	_ = /*e*/Greeting/*f*/
+	_ = /*g*/Third/*h*/
+}
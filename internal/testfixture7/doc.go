@@ -0,0 +1,31 @@
+package testfixture7
+
+import "strings"
+
+// Color is a color.
+type Color int
+
+// The colors.
+const (
+	Red Color = iota // Red is red.
+	Green
+)
+
+type Point struct {
+	// X is the horizontal coordinate.
+	X int
+	Y int // Y is the vertical coordinate.
+}
+
+// Origin is the origin.
+var Origin = Point{}
+
+func use() string {
+	c := /*a*/Red/*b*/
+	c = /*c*/Green/*d*/
+	x := Origin./*e*/X/*f*/ + Origin./*g*/Y/*h*/
+	p := /*i*/Origin/*j*/
+	var b strings./*k*/Builder/*l*/
+	_, _, _ = c, x, p
+	return b.String()
+}
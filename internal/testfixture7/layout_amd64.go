@@ -0,0 +1,20 @@
+package testfixture7
+
+type Record struct {
+	Flag  bool  `json:"flag,omitempty" db:"flag"`
+	ID    int64 `json:"id" yaml:"id"`
+	Small int8  `json:"-"`
+	Name  string
+}
+
+func useRecord(r Record) string {
+	if r./*a*/Flag/*b*/ {
+		return r./*c*/Name/*d*/
+	}
+	var r2 /*e*/Record/*f*/
+	return r2.Name
+}
+
+func useRecordContents(r Record) {
+	/*g*/
+}
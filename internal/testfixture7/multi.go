@@ -0,0 +1,14 @@
+package testfixture7
+
+func pair() (int, string) { return 1, "one" }
+
+var (
+	zero          = 0
+	first, second = pair()
+)
+
+func useMulti() {
+	a, b := pair()
+	_, _ = a, /*a*/b/*b*/
+	_ = /*c*/second/*d*/
+}
@@ -0,0 +1,2 @@
+// Package testfixture7 contains declarations with documentation.
+package testfixture7
@@ -0,0 +1,26 @@
+package testfixture7
+
+type Base struct {
+	ID int
+}
+
+func (b *Base) Method() int { return b.ID }
+
+type Inner struct {
+	*Base
+}
+
+type Outer struct {
+	Name string
+	Inner
+}
+
+func usePromotion(a Outer) int {
+	return a./*a*/Method/*b*/() + a./*c*/ID/*d*/
+}
+
+func (a *Outer) Reset() {}
+
+func useContents(o Outer) {
+	/*e*/
+}
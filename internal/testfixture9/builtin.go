@@ -0,0 +1,14 @@
+package testfixture9
+
+func builtins(xs []int, err error) (int, interface{}) {
+	const (
+		a = /*a*/iota/*b*/
+	)
+	var p *int = /*c*/nil/*d*/
+	if p != nil || /*e*/true/*f*/ {
+		xs = /*g*/append/*h*/(xs, a)
+	}
+	var e /*i*/error/*j*/ = err
+	_ = /*k*/e.Error/*l*/()
+	return /*m*/len/*n*/(xs), /*o*/err.Error/*p*/
+}
@@ -0,0 +1,19 @@
+package testfixture9
+
+type Point struct {
+	X, Y int
+}
+
+type Segment struct {
+	From, To Point
+}
+
+var segments = []Segment{
+	{/*a*/From/*b*/: Point{/*c*/X/*d*/: 1}, To: Point{Y: 2}},
+}
+
+var named = map[string]*Point{
+	"origin": /*e*/{/*f*/Y/*g*/: 0}/*h*/,
+}
+
+var grid = [2][2]Point{/*i*/{{X: 1}, {Y: 1}}/*j*/}
@@ -0,0 +1,50 @@
+package testfixture9
+
+import "sync"
+
+func search(grid [][]int, n int) (row, col int, found bool) {
+/*j*/outer/*k*/:
+	for i := range grid {
+		for j := range grid[i] {
+			if grid[i][j] < 0 {
+				/*a*/continue /*l*/outer/*m*/
+			}
+			if grid[i][j] == n {
+				row, col, found = i, j, true
+				/*b*/break outer
+			}
+		}
+	}
+	/*c*/return
+}
+
+func classify(n int) string {
+	s := ""
+	switch {
+	case n < 0:
+		s = "negative"
+		/*d*/fallthrough
+	case n == 0:
+		s += "small"
+	default:
+		for {
+			/*e*/break
+		}
+	}
+	if s == "" {
+		/*f*/goto end
+	}
+end:
+	return s
+}
+
+func spawn(wg *sync.WaitGroup, f func()) {
+	/*g*/defer wg.Done()
+	/*h*/go func() {
+		f()
+	}()
+	g := func() int {
+		/*i*/return 1
+	}
+	_ = g
+}
@@ -0,0 +1,24 @@
+package /*a*/testfixture9/*b*/
+
+import "strconv"
+
+// Shape is a shape.
+type /*c*/Shape/*d*/ struct {
+	/*e*/Sides/*f*/ int
+}
+
+// perimeter returns the perimeter of a regular shape.
+func /*g*/perimeter/*h*/(s Shape, /*i*/side/*j*/ float64) float64 {
+	/*k*/total/*l*/ := float64(s.Sides) * side
+	return total
+}
+
+func kind(x interface{}) string {
+	switch /*m*/v/*n*/ := x.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case string:
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,2 @@
+// Package testfixture9 uses packages, builtins and definitions.
+package testfixture9
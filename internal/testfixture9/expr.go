@@ -0,0 +1,27 @@
+package testfixture9
+
+import "strconv"
+
+const kib = /*a*/1 << 10/*b*/
+
+type celsius float64
+
+func (c celsius) String() string {
+	return strconv.FormatFloat(float64(c), 'f', 1, 64)
+}
+
+func exprs(xs []int, m map[string]int, s fmt2) {
+	xs[0] = /*c*/xs[1]/*d*/ + 1
+	_ = /*e*/m["a"]/*f*/
+	_ = /*g*/celsius(21.5)/*h*/
+	_ = /*i*/len(xs)/*j*/
+	_ = /*k*/strconv.Itoa(kib)/*l*/
+	_ = /*m*/celsius(0).String()/*n*/
+	_ = /*o*/s.Format()/*p*/
+	/*q*/println("x")/*r*/
+	_ = /*s*/(kib + 1) * 2/*t*/
+}
+
+type fmt2 interface {
+	Format() string
+}
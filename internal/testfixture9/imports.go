@@ -0,0 +1,23 @@
+package testfixture9
+
+import (
+	/*c*/"strconv"
+	str "strings"
+	/*i*/. "math"
+
+	"github.com/aarzilli/go2def/internal/testfixture7"
+)
+
+func convert(n int) string {
+	return /*a*/strconv/*b*/.Itoa(n)
+}
+
+func upper(s string) string {
+	return /*e*/str/*f*/.ToUpper(s)
+}
+
+func abs(x float64) float64 {
+	return Abs(x)
+}
+
+var origin = /*g*/testfixture7/*h*/.Origin
@@ -0,0 +1,13 @@
+package testfixture9
+
+const mask = /*a*/0xF0/*b*/
+
+var flags uint8 = /*c*/0b1010/*d*/
+
+var letter = /*e*/'é'/*f*/
+
+var header = /*g*/"GIF89a\x00\n"/*h*/
+
+var raw = /*i*/`a\nb`/*j*/
+
+var ratio = /*k*/1.5/*l*/
@@ -0,0 +1,10 @@
+package testfixture9
+
+func double(x int) int {
+	return x * 2
+}
+
+func sloppy(y int) int {
+	total := double(/*a*/(/*c*/y+1 /*d*/))/*b*/
+	return /*e*/total
+}
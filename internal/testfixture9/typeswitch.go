@@ -0,0 +1,29 @@
+package testfixture9
+
+type Polygon interface {
+	Corners() int
+}
+
+type Triangle struct{}
+
+func (Triangle) Corners() int { return 3 }
+
+type Square struct{}
+
+func (*Square) Corners() int { return 4 }
+
+type Hexagon struct{}
+
+func (Hexagon) Corners() int { return 6 }
+
+func corners(p Polygon) int {
+	/*a*/switch v := p.(type) {
+	case Triangle:
+		return /*b*/v/*c*/.Corners()
+	/*f*/case *Square, nil:
+		_ = /*d*/v/*e*/
+	default:
+		return p.Corners() + /*g*/v/*h*/.Corners()
+	}
+	return 0
+}
@@ -0,0 +1,167 @@
+package go2def
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// tagKeys are the struct tag keys that are parsed when describing a field.
+var tagKeys = []string{"json", "yaml", "db"}
+
+// sizes returns the sizes of types for the GOARCH selected by
+// decorateConfig.
+func (ctx *context) sizes() types.Sizes {
+	goarch := ctx.goarch
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	if sizes := types.SizesFor("gc", goarch); sizes != nil {
+		return sizes
+	}
+	return types.SizesFor("gc", "amd64")
+}
+
+// describeField adds the struct tag of the idx-th field of st and its
+// offset, size and alignment to the description.
+func describeField(ctx *context, st *types.Struct, idx int) {
+	if st == nil {
+		return
+	}
+	for _, line := range fieldDescr(ctx, st, idx) {
+		ctx.out.field(line)
+	}
+}
+
+// fieldDescr returns the struct tag of the idx-th field of st, the values of
+// the json, yaml and db keys of the tag and the offset, size and alignment
+// of the field.
+func fieldDescr(ctx *context, st *types.Struct, idx int) []string {
+	r := []string{}
+	if tag := st.Tag(idx); tag != "" {
+		r = append(r, fmt.Sprintf("tag: `%s`", tag))
+		for _, key := range tagKeys {
+			if v, ok := reflect.StructTag(tag).Lookup(key); ok {
+				r = append(r, fmt.Sprintf("%s: %s", key, describeTagValue(v)))
+			}
+		}
+	}
+
+	offsets, sizes, aligns, ok := structLayout(ctx, st)
+	if ok {
+		r = append(r, fmt.Sprintf("offset: %d, size: %d, align: %d", offsets[idx], sizes[idx], aligns[idx]))
+	}
+	return r
+}
+
+// describeTagValue formats the value of a json, yaml or db struct tag key
+// as its name followed by its options.
+func describeTagValue(v string) string {
+	v2 := strings.Split(v, ",")
+	name := v2[0]
+	switch name {
+	case "":
+		name = "(field name)"
+	case "-":
+		if len(v2) == 1 {
+			return "- (ignored)"
+		}
+	}
+	if len(v2) > 1 {
+		return fmt.Sprintf("%s (%s)", name, strings.Join(v2[1:], ", "))
+	}
+	return name
+}
+
+// findFieldStruct returns the struct type containing the field declared by
+// decl and the index of the field in it.
+func findFieldStruct(decl *declaration) (*types.Struct, int) {
+	if _, isfield := decl.node.(*ast.Field); !isfield || len(decl.path) < 3 {
+		return nil, 0
+	}
+	stnode, isstruct := decl.path[len(decl.path)-3].(*ast.StructType)
+	if !isstruct {
+		return nil, 0
+	}
+	st, _ := decl.pkg.TypesInfo.TypeOf(stnode).(*types.Struct)
+	if st == nil {
+		return nil, 0
+	}
+	idx := 0
+	for _, field := range stnode.Fields.List {
+		if len(field.Names) == 0 {
+			// embedded field
+			if field == decl.node {
+				return st, idx
+			}
+			idx++
+			continue
+		}
+		for _, name := range field.Names {
+			if name == decl.id {
+				return st, idx
+			}
+			idx++
+		}
+	}
+	return nil, 0
+}
+
+// structLayout returns the offset, size and alignment of each field of st.
+// The layout of structs containing type parameters is not defined and ok
+// will be false.
+func structLayout(ctx *context, st *types.Struct) (offsets, sizes, aligns []int64, ok bool) {
+	if hasTypeParams(st) {
+		return nil, nil, nil, false
+	}
+
+	sz := ctx.sizes()
+	fields := make([]*types.Var, st.NumFields())
+	for i := range fields {
+		fields[i] = st.Field(i)
+		sizes = append(sizes, sz.Sizeof(fields[i].Type()))
+		aligns = append(aligns, sz.Alignof(fields[i].Type()))
+	}
+	offsets = sz.Offsetsof(fields)
+	return offsets, sizes, aligns, true
+}
+
+// describeLayout adds the memory layout of typ, if it is a struct, to the
+// description, pointing out padding between fields.
+func describeLayout(ctx *context, typ types.Type) {
+	if typ == nil {
+		return
+	}
+	if ptyp, isptr := typ.(*types.Pointer); isptr {
+		typ = ptyp.Elem()
+	}
+	st, isstruct := typ.Underlying().(*types.Struct)
+	if !isstruct {
+		return
+	}
+	offsets, sizes, _, ok := structLayout(ctx, st)
+	if !ok {
+		return
+	}
+	sz := ctx.sizes()
+	size, align := sz.Sizeof(st), sz.Alignof(st)
+
+	out := new(bytes.Buffer)
+	fmt.Fprintf(out, "\nLayout (size %d, align %d):\n", size, align)
+	end := int64(0)
+	for i := 0; i < st.NumFields(); i++ {
+		if offsets[i] > end {
+			fmt.Fprintf(out, "\t%d\tpadding (%d bytes)\n", end, offsets[i]-end)
+		}
+		fmt.Fprintf(out, "\t%d\t%s (size %d)\n", offsets[i], printTypesObjectNice(st.Field(i)), sizes[i])
+		end = offsets[i] + sizes[i]
+	}
+	if size > end {
+		fmt.Fprintf(out, "\t%d\tpadding (%d bytes)\n", end, size-end)
+	}
+	ctx.out.typeContents(out.String())
+}
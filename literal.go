@@ -0,0 +1,110 @@
+package go2def
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// maxLitBytes is the maximum number of bytes of a string literal that are
+// written in its description.
+const maxLitBytes = 32
+
+// describeBasicLit adds to the description the value of an integer literal
+// in all bases, the code point and unicode category of a rune literal or the
+// length and bytes of a string literal, followed by the type of the literal:
+// its default type or the one it is converted to by its context.
+func describeBasicLit(ctx *context, pkg *packages.Package, lit *ast.BasicLit) {
+	tv := pkg.TypesInfo.Types[lit]
+
+	switch lit.Kind {
+	case token.INT:
+		if tv.Value != nil && tv.Value.Kind() == constant.Int {
+			ctx.out.expr(intBases(tv.Value))
+		}
+	case token.CHAR:
+		if tv.Value != nil && tv.Value.Kind() == constant.Int {
+			if r, exact := constant.Int64Val(tv.Value); exact {
+				ctx.out.expr(runeDescr(rune(r)))
+			}
+		}
+	case token.STRING:
+		s, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			break
+		}
+		ctx.out.expr(fmt.Sprintf("length: %d bytes, %d runes", len(s), utf8.RuneCountInString(s)))
+		if lit.Value[0] == '"' && strings.Contains(lit.Value, `\`) {
+			// escape sequences
+			if len(s) > maxLitBytes {
+				ctx.out.expr(fmt.Sprintf("bytes: % x ...", s[:maxLitBytes]))
+			} else {
+				ctx.out.expr(fmt.Sprintf("bytes: % x", s))
+			}
+		}
+	}
+
+	if basic, isbasic := tv.Type.(*types.Basic); isbasic && basic.Info()&types.IsUntyped != 0 {
+		ctx.out.expr(fmt.Sprintf("untyped, default type %s", printTypesTypeNice(types.Default(basic))))
+	} else if tv.Type != nil {
+		if def := litDefaultType(lit.Kind); def != nil && types.Identical(tv.Type, def) {
+			ctx.out.expr(fmt.Sprintf("default type %s", printTypesTypeNice(tv.Type)))
+		} else {
+			ctx.out.expr(fmt.Sprintf("converted to %s by its context", printTypesTypeNice(tv.Type)))
+		}
+	}
+}
+
+// litDefaultType returns the type given to a literal of kind when the
+// context doesn't require a different one.
+func litDefaultType(kind token.Token) types.Type {
+	switch kind {
+	case token.INT:
+		return types.Default(types.Typ[types.UntypedInt])
+	case token.FLOAT:
+		return types.Default(types.Typ[types.UntypedFloat])
+	case token.IMAG:
+		return types.Default(types.Typ[types.UntypedComplex])
+	case token.CHAR:
+		return types.Default(types.Typ[types.UntypedRune])
+	case token.STRING:
+		return types.Default(types.Typ[types.UntypedString])
+	}
+	return nil
+}
+
+// intBases returns the integer v formatted in decimal, hexadecimal, octal
+// and binary.
+func intBases(v constant.Value) string {
+	n := constant.Val(v)
+	return fmt.Sprintf("decimal: %d, hex: %#x, octal: 0o%o, binary: 0b%b", n, n, n, n)
+}
+
+// runeDescr returns the code point of r followed by its unicode categories.
+// The standard library has no table of character names.
+func runeDescr(r rune) string {
+	if !utf8.ValidRune(r) {
+		return fmt.Sprintf("code point: %U (invalid)", r)
+	}
+	cats := []string{}
+	for name, table := range unicode.Categories {
+		// general categories like Lu, not groups like L or LC
+		if len(name) == 2 && unicode.IsLower(rune(name[1])) && unicode.Is(table, r) {
+			cats = append(cats, name)
+		}
+	}
+	sort.Strings(cats)
+	if len(cats) == 0 {
+		return fmt.Sprintf("code point: %#U", r)
+	}
+	return fmt.Sprintf("code point: %#U, category: %s", r, strings.Join(cats, ", "))
+}
@@ -2,6 +2,7 @@ package go2def
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"go/ast"
 	"go/parser"
@@ -64,6 +65,20 @@ type Config struct {
 
 	Modfiles map[string][]byte // modified files
 
+	Cache *Cache // if not nil loaded packages are saved here and reused by later queries
+
+	Record *Recording // if not nil the inputs of the query are saved here
+
+	JSON bool // write the description as JSON
+
+	Stdlib bool // also consider types of the standard library in Implements
+
+	Depth int // depth of the call tree written by Callers and Callees, defaults to 1
+
+	Layout bool // describe the memory layout of structs
+
+	Path bool // describe the nodes enclosing the selection
+
 	Verbose           bool
 	DebugLoadPackages bool
 
@@ -79,10 +94,23 @@ type context struct {
 
 	originalPath string
 	buildTags    []string
+	goos, goarch string
+
+	env []string // environment to use instead of os.Environ()
+
+	replay *Recording // recording being replayed
+
+	sourcesMu sync.Mutex
+	sources   map[string][]byte // source of the parsed files
 }
 
 func Describe(path string, pos [2]int, cfg *Config) Description {
-	var ctx context
+	ctx := newContext(path, cfg)
+	return describe(ctx, path, pos)
+}
+
+func newContext(path string, cfg *Config) *context {
+	ctx := &context{}
 
 	if cfg != nil {
 		ctx.Config = *cfg
@@ -99,13 +127,21 @@ func Describe(path string, pos [2]int, cfg *Config) Description {
 
 	ctx.originalPath = path
 
-	err := loadPackages(&ctx, path)
+	return ctx
+}
+
+func describe(ctx *context, path string, pos [2]int) Description {
+	if ctx.Record != nil {
+		ctx.Record.start(ctx, path, pos)
+	}
+
+	err := loadPackages(ctx, path)
 	if err != nil {
-		cfg.out.err("loading packages: %v", err)
-		return nil
+		ctx.out.err("loading packages: %v", err)
+		return ctx.out
 	}
 
-	if cfg.Verbose && cfg.DebugLoadPackages {
+	if ctx.Verbose && ctx.DebugLoadPackages {
 		pkgit := visit.Packages(ctx.pkgs)
 		for pkgit.Next() {
 			if pkgit.Pkg() != nil {
@@ -114,60 +150,75 @@ func Describe(path string, pos [2]int, cfg *Config) Description {
 		}
 	}
 
-	found := false
-	pkgit := visit.Packages(ctx.pkgs)
-	for pkgit.Next() {
-		if pkgit.Pkg() == nil {
-			continue
-		}
-		pkg := pkgit.Pkg()
-		for i := range pkg.Syntax {
-			//TODO: better way to match file?
-			if strings.HasSuffix(pkg.CompiledGoFiles[i], path) {
-				node := findNodeInFile(pkg, pkg.Syntax[i], pos, pos[0] == pos[1])
-				if node != nil {
-					found = true
-					describeNode(&ctx, pkg, node)
-				}
-				break
-			}
-		}
-		if found {
-			break
+	pkg, node := findNodeAt(ctx, path, pos)
+	found := node != nil
+	if found {
+		describeNode(ctx, pkg, node)
+		if ctx.Path {
+			describePath(ctx, pkg, node)
 		}
 	}
 
-	if !found {
-		fmt.Fprintf(ctx.Out, "nothing found\n")
-	} else {
-		ctx.out.writeTo(ctx.Out)
+	if ctx.Record != nil {
+		ctx.Record.finish(ctx)
 	}
 
+	ctx.writeOut(found)
+
 	return ctx.out
 }
 
+// writeOut writes the description to ctx.Out.
+func (ctx *context) writeOut(found bool) {
+	switch {
+	case ctx.JSON:
+		ctx.out.WriteJSON(ctx.Out)
+	case !found:
+		fmt.Fprintf(ctx.Out, "nothing found\n")
+	default:
+		ctx.out.writeTo(ctx.Out)
+	}
+}
+
 func (ctx *context) getPosition(pos token.Pos) token.Position {
 	return ctx.currentFileSet.Position(pos)
 }
 
+// position returns the position of pos with $GOROOT replaced by the actual
+// GOROOT directory.
+func (ctx *context) position(pos token.Pos) token.Position {
+	p := ctx.getPosition(pos)
+	p.Filename = replaceGoroot(ctx, p.Filename)
+	return p
+}
+
 func (ctx *context) getFileSet(pos token.Pos) *token.FileSet {
 	return ctx.currentFileSet
 }
 
 func (ctx *context) parseFile() func(*token.FileSet, string, []byte) (*ast.File, error) {
-	if ctx.Modfiles == nil {
-		return nil
-	}
-
 	return func(fset *token.FileSet, name string, obuf []byte) (*ast.File, error) {
-		if buf, modified := ctx.Modfiles[name]; modified {
-			return parser.ParseFile(fset, name, buf, parser.ParseComments)
-		} else {
-			return parser.ParseFile(fset, name, obuf, parser.ParseComments)
+		buf := obuf
+		if mbuf, modified := ctx.Modfiles[name]; modified {
+			buf = mbuf
 		}
+		ctx.sourcesMu.Lock()
+		if ctx.sources == nil {
+			ctx.sources = make(map[string][]byte)
+		}
+		ctx.sources[name] = buf
+		ctx.sourcesMu.Unlock()
+		return parser.ParseFile(fset, name, buf, parser.ParseComments)
 	}
 }
 
+// source returns the source that was parsed for filename.
+func (ctx *context) source(filename string) []byte {
+	ctx.sourcesMu.Lock()
+	defer ctx.sourcesMu.Unlock()
+	return ctx.sources[filename]
+}
+
 func isGoarch(ctx *context, x string) bool {
 	_, ok := ctx.PossibleGoarch()[x]
 	return ok
@@ -194,7 +245,11 @@ func decorateConfig(ctx *context, cfg *packages.Config) *packages.Config {
 	}
 
 	if len(cfg.Env) == 0 {
-		cfg.Env = os.Environ()
+		if ctx.env != nil {
+			cfg.Env = append([]string{}, ctx.env...)
+		} else {
+			cfg.Env = os.Environ()
+		}
 	}
 
 	goarch := runtime.GOARCH
@@ -216,6 +271,8 @@ func decorateConfig(ctx *context, cfg *packages.Config) *packages.Config {
 		}
 	}
 
+	ctx.goos, ctx.goarch = goos, goarch
+
 	if ctx.buildTags == nil {
 		findBuildTags(ctx, goos, goarch)
 	}
@@ -319,11 +376,64 @@ func loadPackages(ctx *context, path string) error {
 		ParseFile: ctx.parseFile(),
 	}
 	decorateConfig(ctx, cfg)
+	if ctx.Cache != nil && ctx.Record == nil {
+		if fset, pkgs, sources, ok := ctx.Cache.lookup(cfg, path, ctx.Modfiles); ok {
+			ctx.currentFileSet = fset
+			ctx.pkgs = pkgs
+			ctx.sources = sources
+			return nil
+		}
+	}
 	var err error
-	ctx.pkgs, err = packages.Load(cfg, "file="+path)
+	ctx.pkgs, err = ctx.load(cfg, "file="+path)
+	if err == nil && ctx.Cache != nil && ctx.Record == nil {
+		ctx.Cache.add(ctx, cfg, path, ctx.pkgs)
+	}
 	return err
 }
 
+// load calls packages.Load, recording its result if needed. When replaying
+// the module information of the recording is restored.
+func (ctx *context) load(cfg *packages.Config, patterns ...string) ([]*packages.Package, error) {
+	if ctx.Record != nil {
+		cfg.Mode |= packages.NeedExportsFile
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err == nil && ctx.Record != nil {
+		ctx.Record.addLoad(cfg, patterns, pkgs)
+	}
+	if err == nil && ctx.replay != nil {
+		ctx.replay.restoreModules(patterns, pkgs)
+	}
+	return pkgs, err
+}
+
+// findNodeAt returns the node selected by pos in the file at path and the
+// package containing it.
+func findNodeAt(ctx *context, path string, pos [2]int) (*packages.Package, ast.Node) {
+	pkgit := visit.Packages(ctx.pkgs)
+	for pkgit.Next() {
+		if pkgit.Pkg() == nil {
+			continue
+		}
+		pkg := pkgit.Pkg()
+		for i := range pkg.Syntax {
+			//TODO: better way to match file?
+			if strings.HasSuffix(pkg.CompiledGoFiles[i], path) {
+				node := findNodeInFile(pkg, pkg.Syntax[i], pos, pos[0] == pos[1])
+				if node == nil {
+					node = findEnclosingNode(ctx, pkg, pkg.Syntax[i], pkg.CompiledGoFiles[i], pos)
+				}
+				if node != nil {
+					return pkg, node
+				}
+				break
+			}
+		}
+	}
+	return nil, nil
+}
+
 func findNodeInFile(pkg *packages.Package, root *ast.File, pos [2]int, autoexpand bool) ast.Node {
 	v := &exactVisitor{pos, pkg, autoexpand, nil}
 	ast.Walk(v, root)
@@ -344,11 +454,21 @@ func (v *exactVisitor) Visit(node ast.Node) ast.Visitor {
 	if v.pkg.Fset.Position(node.Pos()).Offset == v.pos[0] && v.pkg.Fset.Position(node.End()).Offset == v.pos[1] {
 		v.ret = node
 	} else if v.autoexpand && v.ret == nil {
-		switch node.(type) {
+		switch node := node.(type) {
 		case *ast.Ident, *ast.SelectorExpr:
 			if v.pkg.Fset.Position(node.Pos()).Offset == v.pos[0] || v.pkg.Fset.Position(node.End()).Offset == v.pos[0] {
 				v.ret = node
 			}
+		case *ast.TypeSwitchStmt:
+			// on the switch keyword
+			if off := v.pkg.Fset.Position(node.Pos()).Offset; off <= v.pos[0] && v.pos[0] <= off+len("switch") {
+				v.ret = node
+			}
+		case *ast.ImportSpec:
+			// anywhere inside the import path
+			if v.pkg.Fset.Position(node.Path.Pos()).Offset <= v.pos[0] && v.pos[0] <= v.pkg.Fset.Position(node.Path.End()).Offset {
+				v.ret = node.Path
+			}
 		}
 	}
 	return v
@@ -357,26 +477,87 @@ func (v *exactVisitor) Visit(node ast.Node) ast.Visitor {
 func describeNode(ctx *context, pkg *packages.Package, node ast.Node) {
 	switch node := node.(type) {
 	case *ast.Ident:
+		if pkgname := identPkgName(pkg, node); pkgname != nil {
+			describePackage(ctx, pkgname.Imported(), pkgname.Name(), pkg.Fset.Position(pkgname.Pos()))
+			return
+		}
+
 		obj := pkg.TypesInfo.Uses[node]
-		if obj == nil || obj.Pkg() == nil {
-			ctx.out.err("unknown identifier %v\n", node)
+		if obj == nil {
+			obj = pkg.TypesInfo.Defs[node]
+		}
+		if obj == nil {
+			if !describeImplicit(ctx, pkg, node) {
+				ctx.out.err("unknown identifier %v\n", node)
+			}
+			return
+		}
+		if obj.Pkg() == nil {
+			describeUniverse(ctx, obj)
+			return
+		}
+		if _, islabel := obj.(*types.Label); islabel {
+			// labels have no type
+			ctx.out.object(obj)
+			ctx.out.pos(ctx.position(obj.Pos()))
+			return
+		}
+		if sw, clause := typeSwitchOf(pkg, obj); sw != nil {
+			describeTypeSwitchVar(ctx, pkg, obj, sw, clause)
 			return
 		}
 
-		declnode := findNodeInPackages(ctx, obj.Pkg().Path(), obj.Pos())
-		if ctx.Verbose {
-			log.Printf("declaration node %v\n", declnode)
+		decl := findNodeInPackages(ctx, obj)
+		if ctx.Verbose && decl != nil {
+			log.Printf("declaration node %v\n", decl.node)
 		}
 
-		if declnode != nil {
-			describeDeclaration(ctx, declnode, obj.Type())
+		if decl != nil {
+			describeDeclaration(ctx, decl, obj.Type())
+			if c, isconst := obj.(*types.Const); isconst {
+				describeConst(ctx, c)
+			}
+			if v, isvar := obj.(*types.Var); isvar && v.IsField() {
+				st, idx := findFieldStruct(decl)
+				if _, sel := selectionOf(pkg, node); sel != nil && sel.Kind() == types.FieldVal {
+					// the struct of an instance has the layout of the instance
+					st, idx = fieldStruct(sel.Recv(), sel.Index())
+				}
+				describeField(ctx, st, idx)
+			}
+			describeInstance(ctx, pkg, node)
+			if _, istype := obj.(*types.TypeName); istype {
+				describeTypeParam(ctx, obj.Type())
+			}
+			if _, isfunc := decl.node.(*ast.FuncDecl); !isfunc {
+				describeDoc(ctx, decl)
+			}
+			if ctx.Layout {
+				describeLayout(ctx, obj.Type())
+			}
+			if expr, sel := selectionOf(pkg, node); sel != nil {
+				describePromotion(ctx, expr, sel)
+			}
 
-			ctx.out.pos(pos2str(ctx, ctx.getPosition(declnode.Pos())))
+			ctx.out.pos(ctx.position(decl.id.Pos()))
 		} else {
 			ctx.out.object(obj)
 			describeType(ctx, "type:", obj.Type())
+			if c, isconst := obj.(*types.Const); isconst {
+				describeConst(ctx, c)
+			}
+			describeInstance(ctx, pkg, node)
+			if _, istype := obj.(*types.TypeName); istype {
+				describeTypeParam(ctx, obj.Type())
+			}
+			if ctx.Layout {
+				describeLayout(ctx, obj.Type())
+			}
+			if expr, sel := selectionOf(pkg, node); sel != nil {
+				describePromotion(ctx, expr, sel)
+			}
 
-			ctx.out.pos(pos2str(ctx, ctx.getPosition(obj.Pos())))
+			ctx.out.pos(ctx.position(obj.Pos()))
 		}
 
 	case *ast.SelectorExpr:
@@ -388,7 +569,7 @@ func describeNode(ctx *context, pkg *packages.Package, node ast.Node) {
 			}
 			if typeOfExpr := pkg.TypesInfo.Types[node.X]; typeOfExpr.Type != nil {
 				describeType(ctx, "receiver:", typeOfExpr.Type)
-				describeTypeContents(&ctx.out, typeOfExpr.Type, node.Sel.String())
+				describeTypeContents(ctx, typeOfExpr.Type, node.Sel.String())
 				return
 			}
 			ctx.out.err(fmt.Sprintf("unknown selector expression %s", printerSprint(ctx.getFileSet(node.Pos()), node)))
@@ -396,14 +577,18 @@ func describeNode(ctx *context, pkg *packages.Package, node ast.Node) {
 		}
 
 		obj := sel.Obj()
+		if obj.Pkg() == nil {
+			describeUniverse(ctx, obj)
+			return
+		}
 
 		fallbackdescr := true
 
-		declnode := findNodeInPackages(ctx, obj.Pkg().Path(), obj.Pos())
-		pos := ctx.getPosition(obj.Pos())
-		if declnode != nil {
-			pos = ctx.getPosition(declnode.Pos())
-			switch declnode := declnode.(type) {
+		decl := findNodeInPackages(ctx, obj)
+		pos := ctx.position(obj.Pos())
+		if decl != nil {
+			pos = ctx.position(decl.id.Pos())
+			switch declnode := decl.node.(type) {
 			case *ast.FuncDecl:
 				ctx.out.funcHeader(ctx.getFileSet(declnode.Pos()), declnode)
 				fallbackdescr = false
@@ -415,13 +600,61 @@ func describeNode(ctx *context, pkg *packages.Package, node ast.Node) {
 
 			describeType(ctx, "receiver:", sel.Recv())
 			describeType(ctx, "type:", sel.Type())
+			if sel.Kind() == types.FieldVal {
+				// the struct of an instance has the layout of the instance
+				st, idx := fieldStruct(sel.Recv(), sel.Index())
+				describeField(ctx, st, idx)
+			}
+			describeDoc(ctx, decl)
+			if ctx.Layout {
+				describeLayout(ctx, sel.Type())
+			}
+		}
+		describeSelectionInstance(ctx, sel)
+		describePromotion(ctx, node, sel)
+
+		ctx.out.pos(pos)
+
+	case *ast.TypeSwitchStmt:
+		describeTypeSwitch(ctx, pkg, node)
+
+	case *ast.ReturnStmt:
+		describeReturn(ctx, pkg, node)
+
+	case *ast.BranchStmt:
+		describeBranch(ctx, pkg, node)
+
+	case *ast.DeferStmt:
+		describeDeferredCall(ctx, pkg, token.DEFER, node.Call)
+
+	case *ast.GoStmt:
+		describeDeferredCall(ctx, pkg, token.GO, node.Call)
+
+	case *ast.ImportSpec:
+		if pkgname := importedPkgName(pkg, node); pkgname != nil {
+			describePackage(ctx, pkgname.Imported(), pkgname.Name(), pkg.Fset.Position(pkgname.Pos()))
 		}
 
-		ctx.out.pos(pos2str(ctx, pos))
+	case *ast.BasicLit:
+		if spec := importSpecOf(pkg, node); spec != nil {
+			describeNode(ctx, pkg, spec)
+			return
+		}
+		typeAndVal := pkg.TypesInfo.Types[node]
+		describeType(ctx, "type:", typeAndVal.Type)
+		describeExpr(ctx, pkg, node)
+		describeBasicLit(ctx, pkg, node)
 
 	case ast.Expr:
 		typeAndVal := pkg.TypesInfo.Types[node]
 		describeType(ctx, "type:", typeAndVal.Type)
+		describeExpr(ctx, pkg, node)
+		if lit, islit := node.(*ast.CompositeLit); islit {
+			describeElidedType(ctx, pkg, lit)
+		}
+		if ctx.Layout {
+			describeLayout(ctx, typeAndVal.Type)
+		}
 	}
 }
 
@@ -431,10 +664,10 @@ func printerSprint(fset *token.FileSet, node ast.Node) string {
 	return buf.String()
 }
 
-func describeDeclaration(ctx *context, declnode ast.Node, typ types.Type) {
+func describeDeclaration(ctx *context, decl *declaration, typ types.Type) {
 	normaldescr := true
 
-	switch declnode := declnode.(type) {
+	switch declnode := decl.node.(type) {
 	case *ast.FuncDecl:
 		ctx.out.funcHeader(ctx.getFileSet(declnode.Pos()), declnode)
 		normaldescr = false
@@ -443,7 +676,78 @@ func describeDeclaration(ctx *context, declnode ast.Node, typ types.Type) {
 
 	if normaldescr {
 		describeType(ctx, "type:", typ)
+		describeAssignment(ctx, decl)
+	}
+}
+
+// describeAssignment adds the expression assigned to the identifier of decl
+// by its var or const spec or short variable declaration to the
+// description. The expression of a const spec without values is the one
+// implicitly repeated from the previous spec of its group.
+func describeAssignment(ctx *context, decl *declaration) {
+	var lhs []*ast.Ident
+	var rhs []ast.Expr
+	op := "="
+	id := decl.id
+	declnode := decl.node
+	repeated := false
+	switch declnode := declnode.(type) {
+	case *ast.ValueSpec:
+		lhs, rhs = declnode.Names, declnode.Values
+		if len(rhs) == 0 && declnode.Type == nil {
+			rhs = repeatedConstValues(decl)
+			repeated = rhs != nil
+		}
+	case *ast.AssignStmt:
+		for _, expr := range declnode.Lhs {
+			ident, _ := expr.(*ast.Ident)
+			lhs = append(lhs, ident)
+		}
+		rhs, op = declnode.Rhs, declnode.Tok.String()
+	default:
+		return
+	}
+
+	idx := -1
+	for i := range lhs {
+		if lhs[i] == id {
+			idx = i
+		}
+	}
+	fset := ctx.getFileSet(declnode.Pos())
+	suffix := ""
+	if repeated {
+		suffix = " (implicitly repeated)"
+	}
+	switch {
+	case idx < 0:
+	case len(rhs) == len(lhs):
+		ctx.out.expr(fmt.Sprintf("%s %s %s%s", id.Name, op, printerSprint(fset, rhs[idx]), suffix))
+	case len(rhs) == 1:
+		ctx.out.expr(fmt.Sprintf("%s %s %s (value %d of %d)", id.Name, op, printerSprint(fset, rhs[0]), idx+1, len(lhs)))
+	}
+}
+
+// repeatedConstValues returns the values of the last spec with values that
+// precedes the spec of decl in its const group.
+func repeatedConstValues(decl *declaration) []ast.Expr {
+	if len(decl.path) < 2 {
+		return nil
+	}
+	gendecl, ok := decl.path[len(decl.path)-2].(*ast.GenDecl)
+	if !ok || gendecl.Tok != token.CONST {
+		return nil
 	}
+	var values []ast.Expr
+	for _, spec := range gendecl.Specs {
+		if spec == decl.node {
+			return values
+		}
+		if vspec, ok := spec.(*ast.ValueSpec); ok && len(vspec.Values) > 0 {
+			values = vspec.Values
+		}
+	}
+	return nil
 }
 
 func describeType(ctx *context, prefix string, typ types.Type) {
@@ -453,22 +757,20 @@ func describeType(ctx *context, prefix string, typ types.Type) {
 	}
 	ntyp, isnamed := typ.(*types.Named)
 	if !isnamed {
-		ctx.out.typ(prefix, typstr, "")
+		ctx.out.typ(prefix, typstr, token.Position{})
 		return
 	}
 	obj := ntyp.Obj()
 	if obj == nil {
-		ctx.out.typ(prefix, typstr, "")
+		ctx.out.typ(prefix, typstr, token.Position{})
 		return
 	}
-	pos := ctx.getPosition(obj.Pos())
-	ctx.out.typ(prefix, typstr, pos2str(ctx, pos))
+	ctx.out.typ(prefix, typstr, ctx.position(obj.Pos()))
+	describeOrigin(ctx, ntyp)
 }
 
-func pos2str(ctx *context, pos token.Position) string {
-	filename := pos.Filename
-	filename = replaceGoroot(ctx, filename)
-	return fmt.Sprintf("%s:%d", filename, pos.Line)
+func pos2str(pos token.Position) string {
+	return fmt.Sprintf("%s:%d", pos.Filename, pos.Line)
 }
 
 func replaceGoroot(ctx *context, filename string) string {
@@ -479,7 +781,14 @@ func replaceGoroot(ctx *context, filename string) string {
 	return filename
 }
 
-func describeTypeContents(descr *Description, typ types.Type, prefix string) {
+// describeTypeContents adds the methods and fields of typ whose name starts
+// with prefix to the description. Methods are taken from the method sets of
+// typ and of a pointer to typ, fields include the ones promoted from embedded
+// structs; promoted members are marked with the embedded fields they come
+// from. Fields are followed by their tags and layout, as described by
+// describeField, the offset of promoted fields is relative to the struct
+// declaring them.
+func describeTypeContents(ctx *context, typ types.Type, prefix string) {
 	if prefix == "_" {
 		prefix = ""
 	}
@@ -489,58 +798,139 @@ func describeTypeContents(descr *Description, typ types.Type, prefix string) {
 
 	out := bytes.NewBuffer(make([]byte, 0))
 
-	switch styp := typ.(type) {
-	case *types.Named:
-		typ = styp.Underlying()
-		ms := []*types.Func{}
-		for i := 0; i < styp.NumMethods(); i++ {
-			m := styp.Method(i)
-			if strings.HasPrefix(m.Name(), prefix) {
-				ms = append(ms, m)
+	writeMembers := func(header string, members []string) {
+		if len(members) > 0 {
+			fmt.Fprintf(out, "\n%s:\n", header)
+			for _, m := range members {
+				fmt.Fprintf(out, "\t%s\n", m)
 			}
 		}
-		if len(ms) > 0 {
-			fmt.Fprintf(out, "\nMethods:\n")
-			for _, m := range ms {
-				fmt.Fprintf(out, "\t%s\n", printTypesObjectNice(m))
+	}
+
+	if types.IsInterface(typ) {
+		mset := types.NewMethodSet(typ)
+		ms := []string{}
+		for i := 0; i < mset.Len(); i++ {
+			if m := mset.At(i).Obj(); strings.HasPrefix(m.Name(), prefix) {
+				ms = append(ms, printTypesObjectNice(m))
 			}
 		}
-	case *types.Interface:
-		ms := []*types.Func{}
-		for i := 0; i < styp.NumMethods(); i++ {
-			m := styp.Method(i)
-			if strings.HasPrefix(m.Name(), prefix) {
-				ms = append(ms, m)
+		writeMembers("Methods", ms)
+	} else {
+		valueSet := types.NewMethodSet(typ)
+		ptrSet := types.NewMethodSet(types.NewPointer(typ))
+		valueMs, ptrMs := []string{}, []string{}
+		for i := 0; i < ptrSet.Len(); i++ {
+			sel := ptrSet.At(i)
+			m := sel.Obj()
+			if !strings.HasPrefix(m.Name(), prefix) {
+				continue
 			}
-		}
-		if len(ms) > 0 {
-			fmt.Fprintf(out, "\nMethods:\n")
-			for _, m := range ms {
-				fmt.Fprintf(out, "\t%s\n", printTypesObjectNice(m))
+			s := printTypesObjectNice(m) + promotedFrom(typ, sel.Index())
+			if valueSet.Lookup(m.Pkg(), m.Name()) != nil {
+				valueMs = append(valueMs, s)
+			} else {
+				ptrMs = append(ptrMs, s)
 			}
 		}
+		writeMembers("Methods", valueMs)
+		writeMembers("Pointer methods", ptrMs)
 	}
 
-	if typ, isstruct := typ.(*types.Struct); isstruct {
-		fs := []*types.Var{}
-		for i := 0; i < typ.NumFields(); i++ {
-			f := typ.Field(i)
-			if strings.HasPrefix(f.Name(), prefix) {
-				fs = append(fs, f)
+	if _, isstruct := typ.Underlying().(*types.Struct); isstruct {
+		fs := []string{}
+		for _, name := range fieldNames(typ) {
+			if !strings.HasPrefix(name.Name(), prefix) {
+				continue
+			}
+			obj, index, _ := types.LookupFieldOrMethod(typ, true, name.Pkg(), name.Name())
+			f, isfield := obj.(*types.Var)
+			if !isfield || f != name {
+				// shadowed or ambiguous
+				continue
+			}
+			fs = append(fs, printTypesObjectNice(f)+promotedFrom(typ, index))
+			if st, idx := fieldStruct(typ, index); st != nil {
+				for _, line := range fieldDescr(ctx, st, idx) {
+					fs = append(fs, "\t"+line)
+				}
 			}
 		}
-		if len(fs) > 0 {
-			fmt.Fprintf(out, "\nFields:\n")
-			for _, f := range fs {
-				fmt.Fprintf(out, "\t%s\n", printTypesObjectNice(f))
+		writeMembers("Fields", fs)
+	}
+
+	ctx.out.typeContents(out.String())
+}
+
+// fieldNames returns the fields of the struct typ and of the structs
+// embedded in it, breadth first.
+func fieldNames(typ types.Type) []*types.Var {
+	r := []*types.Var{}
+	seen := make(map[types.Type]bool)
+	queue := []types.Type{typ}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		if ptyp, isptr := t.(*types.Pointer); isptr {
+			t = ptyp.Elem()
+		}
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		st, isstruct := t.Underlying().(*types.Struct)
+		if !isstruct {
+			continue
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			r = append(r, st.Field(i))
+			if st.Field(i).Anonymous() {
+				queue = append(queue, st.Field(i).Type())
 			}
 		}
 	}
+	return r
+}
+
+// fieldStruct returns the struct declaring the field selected by the path
+// index starting from typ, and the index of the field in it.
+func fieldStruct(typ types.Type, index []int) (*types.Struct, int) {
+	if len(index) > 1 {
+		embedded, _ := embeddedFields(typ, index)
+		if embedded == nil {
+			return nil, 0
+		}
+		typ = embedded[len(embedded)-1].Type()
+	}
+	if ptyp, isptr := typ.(*types.Pointer); isptr {
+		typ = ptyp.Elem()
+	}
+	st, isstruct := typ.Underlying().(*types.Struct)
+	if !isstruct {
+		return nil, 0
+	}
+	return st, index[len(index)-1]
+}
 
-	descr.typeContents(out.String())
+// promotedFrom returns a description of the embedded fields a member selected
+// by the path index, starting from typ, is promoted from, or the empty string
+// if the member isn't promoted.
+func promotedFrom(typ types.Type, index []int) string {
+	if len(index) <= 1 {
+		return ""
+	}
+	embedded, _ := embeddedFields(typ, index)
+	if embedded == nil {
+		return ""
+	}
+	return " (from " + embeddingPath(embedded) + ")"
 }
 
-func findNodeInPackages(ctx *context, pkgpath string, pos token.Pos) ast.Node {
+// packageWithSyntax returns the loaded package pkgpath, if it was loaded
+// without syntax it is loaded again, in which case reloaded is true and the
+// positions of its syntax trees do not match the positions of the objects
+// of the original package.
+func packageWithSyntax(ctx *context, pkgpath string) (pkg *packages.Package, reloaded bool) {
 	pkgit := visit.Packages(ctx.pkgs)
 	for pkgit.Next() {
 		pkg := pkgit.Pkg()
@@ -549,100 +939,131 @@ func findNodeInPackages(ctx *context, pkgpath string, pos token.Pos) ast.Node {
 		}
 	}
 
-	pkg := pkgit.Pkg()
+	pkg = pkgit.Pkg()
 	if pkg == nil {
 		// could not find package
-		return nil
+		return nil, false
 	}
-	if pkg.Syntax == nil {
-		if ctx.Verbose {
-			log.Printf("loading syntax for %q", pkg.PkgPath)
-		}
-		pkgs2, err := packages.Load(decorateConfig(ctx, &packages.Config{
-			Mode:      packages.LoadSyntax,
-			Dir:       ctx.Wd,
-			Fset:      pkg.Fset,
-			ParseFile: ctx.parseFile()}), pkg.PkgPath)
-		if err != nil {
-			return nil
-		}
-
-		pkg = pkgs2[0]
-		p := pkg.Fset.Position(pos)
-		filename := replaceGoroot(ctx, p.Filename)
-		//XXX: ideally we would look for pkg.Fset.File(pos).Offset(pos) instead but it seems to be wrong.
-		for i := range pkg.Syntax {
-			node := findDeclByLine(ctx, pkg.Syntax[i], filename, p.Line)
-			if node != nil {
-				return node
-			}
-		}
-	} else {
-		for i := range pkg.Syntax {
-			node := findDecl(pkg.Syntax[i], pos)
-			if node != nil {
-				return node
-			}
-		}
+	if pkg.Syntax != nil {
+		return pkg, false
 	}
-	return nil
-}
 
-func findDecl(root *ast.File, pos token.Pos) ast.Node {
-	v := &exactVisitorForDecl{pos, nil}
-	ast.Walk(v, root)
-	return v.ret
+	if ctx.Verbose {
+		log.Printf("loading syntax for %q", pkg.PkgPath)
+	}
+	pkgs2, err := ctx.load(decorateConfig(ctx, &packages.Config{
+		Mode:      packages.LoadSyntax,
+		Dir:       ctx.Wd,
+		Fset:      pkg.Fset,
+		ParseFile: ctx.parseFile()}), pkg.PkgPath)
+	if err != nil || len(pkgs2) == 0 {
+		return nil, false
+	}
+	return pkgs2[0], true
 }
 
-type exactVisitorForDecl struct {
-	pos token.Pos
-	ret ast.Node
+// declaration is the syntax declaring an object.
+type declaration struct {
+	pkg  *packages.Package // package containing the declaration, it can be a reloaded package
+	path []ast.Node        // nodes enclosing the declaration, from the file to node
+	node ast.Node          // function declaration, type or value spec, short variable declaration or field
+	id   *ast.Ident        // identifier of the object in node
 }
 
-func (v *exactVisitorForDecl) Visit(node ast.Node) ast.Visitor {
-	if node == nil {
-		return v
+// findNodeInPackages returns the declaration of obj. The node is a function
+// declaration, a type or value spec, a short variable declaration, a struct
+// field or an interface method; objects declared elsewhere, like
+// parameters, are not found.
+func findNodeInPackages(ctx *context, obj types.Object) *declaration {
+	if obj == nil || obj.Pkg() == nil || !obj.Pos().IsValid() {
+		return nil
 	}
-	if v.pos >= node.Pos() && v.pos < node.End() {
-		switch node := node.(type) {
-		case *ast.GenDecl, *ast.AssignStmt, *ast.DeclStmt:
-			v.ret = node
-		case *ast.FuncDecl:
-			if v.pos == node.Name.Pos() {
-				v.ret = node
+	pkg, reloaded := packageWithSyntax(ctx, obj.Pkg().Path())
+	if pkg == nil {
+		return nil
+	}
+	match := func(id *ast.Ident) bool {
+		return id.Pos() == obj.Pos()
+	}
+	if reloaded {
+		// The syntax trees of a reloaded package don't share token.Pos
+		// values with obj and objects read from export data have no
+		// offset, identifiers are matched by file, line and column instead.
+		p := ctx.getPosition(obj.Pos())
+		filename := replaceGoroot(ctx, p.Filename)
+		match = func(id *ast.Ident) bool {
+			if id.Name != obj.Name() {
+				return false
 			}
+			q := ctx.getPosition(id.Pos())
+			return q.Line == p.Line && (p.Column == 0 || q.Column == p.Column) && replaceGoroot(ctx, q.Filename) == filename
 		}
 	}
-	return v
-}
-
-func findDeclByLine(ctx *context, root *ast.File, filename string, line int) ast.Node {
-	v := &exactVisitorForFileLine{ctx, filename, line, nil}
-	ast.Walk(v, root)
-	return v.ret
-}
-
-type exactVisitorForFileLine struct {
-	ctx      *context
-	filename string
-	line     int
-	ret      ast.Node
+	for _, file := range pkg.Syntax {
+		if path, id := findDecl(file, match); path != nil {
+			return &declaration{pkg: pkg, path: path, node: path[len(path)-1], id: id}
+		}
+	}
+	return nil
 }
 
-func (v *exactVisitorForFileLine) Visit(node ast.Node) ast.Visitor {
-	if node == nil {
-		return v
+// findDecl returns the identifier of root selected by match and the nodes
+// enclosing its declaration, from root to the declaration.
+func findDecl(root *ast.File, match func(*ast.Ident) bool) ([]ast.Node, *ast.Ident) {
+	var id *ast.Ident
+	ast.Inspect(root, func(node ast.Node) bool {
+		if x, isident := node.(*ast.Ident); isident && id == nil && match(x) {
+			id = x
+		}
+		return id == nil
+	})
+	if id == nil {
+		return nil, nil
 	}
-	p := v.ctx.getPosition(node.Pos())
-	if v.filename == p.Filename && v.line == p.Line {
-		switch node := node.(type) {
-		case *ast.GenDecl, *ast.AssignStmt, *ast.DeclStmt, *ast.FuncDecl, *ast.Field:
-			if v.ret == nil {
-				v.ret = node
+
+	path := pathEnclosing(root, id.Pos(), id.End())
+	for i := len(path) - 1; i >= 0; i-- {
+		switch node := path[i].(type) {
+		case *ast.FuncDecl:
+			if node.Name == id {
+				return path[:i+1], id
+			}
+			// parameters and results
+			return nil, nil
+		case *ast.TypeSpec:
+			if node.Name == id {
+				return path[:i+1], id
+			}
+			// type parameters
+			return nil, nil
+		case *ast.ValueSpec:
+			for _, name := range node.Names {
+				if name == id {
+					return path[:i+1], id
+				}
+			}
+			return nil, nil
+		case *ast.AssignStmt:
+			for _, lhs := range node.Lhs {
+				if lhs == id {
+					return path[:i+1], id
+				}
 			}
+			return nil, nil
+		case *ast.Field:
+			// path[i-1] is the field list
+			if i >= 2 {
+				switch path[i-2].(type) {
+				case *ast.StructType, *ast.InterfaceType:
+					return path[:i+1], id
+				}
+			}
+			return nil, nil
+		case *ast.FuncLit, *ast.RangeStmt, *ast.LabeledStmt:
+			return nil, nil
 		}
 	}
-	return v
+	return nil, nil
 }
 
 func printTypesObjectNice(v types.Object) string {
@@ -662,9 +1083,11 @@ func printTypesTypeNice(t types.Type) string {
 type Description []Info
 
 type Info struct {
-	Kind InfoKind
-	Text string
-	Pos  string
+	Kind     InfoKind
+	Text     string
+	Pos      string         // Position formatted as filename:line
+	Position token.Position // position, the offset is only valid for files that were parsed
+	Depth    int            // nesting level of InfoCall entries
 }
 
 type InfoKind uint8
@@ -677,10 +1100,27 @@ const (
 	InfoType
 	InfoTypeContents
 	InfoPos
+	InfoRef
+	InfoImplements
+	InfoCall
+	InfoDoc
+	InfoValue
+	InfoField
+	InfoPromotion
+	InfoGeneric
+	InfoPackage
+	InfoExpr
+	InfoPath
 )
 
 func (descr Description) writeTo(out io.Writer) {
+	lastFile := ""
 	for _, info := range descr {
+		if info.Kind == InfoRef && info.Position.Filename != lastFile {
+			// references are grouped by file
+			lastFile = info.Position.Filename
+			fmt.Fprintf(out, "%s:\n", lastFile)
+		}
 		info.writeTo(out)
 	}
 }
@@ -716,28 +1156,80 @@ func (descr *Description) funcHeader(fset *token.FileSet, declnode *ast.FuncDecl
 	declnode.Body = body
 }
 
-func (descr *Description) typ(prefix, typeDescr, pos string) {
-	*descr = append(*descr, Info{Kind: InfoType, Text: fmt.Sprintf("%s %s", prefix, typeDescr), Pos: pos})
+func (descr *Description) typ(prefix, typeDescr string, pos token.Position) {
+	info := Info{Kind: InfoType, Text: fmt.Sprintf("%s %s", prefix, typeDescr), Position: pos}
+	if pos.IsValid() {
+		info.Pos = pos2str(pos)
+	}
+	*descr = append(*descr, info)
 }
 
 func (descr *Description) typeContents(contents string) {
 	*descr = append(*descr, Info{Kind: InfoTypeContents, Text: contents})
 }
 
-func (descr *Description) pos(pos string) {
-	*descr = append(*descr, Info{Kind: InfoPos, Pos: pos})
+func (descr *Description) implements(typeDescr string, pos token.Position) {
+	*descr = append(*descr, Info{Kind: InfoImplements, Text: typeDescr, Pos: pos2str(pos), Position: pos})
+}
+
+func (descr *Description) ref(pos token.Position, line string) {
+	*descr = append(*descr, Info{Kind: InfoRef, Text: line, Pos: pos2str(pos), Position: pos})
+}
+
+func (descr *Description) call(name string, pos token.Position, depth int) {
+	*descr = append(*descr, Info{Kind: InfoCall, Text: name, Pos: pos2str(pos), Position: pos, Depth: depth})
+}
+
+func (descr *Description) value(value string) {
+	*descr = append(*descr, Info{Kind: InfoValue, Text: "value: " + value})
+}
+
+func (descr *Description) promotion(text string, pos token.Position) {
+	*descr = append(*descr, Info{Kind: InfoPromotion, Text: text, Pos: pos2str(pos), Position: pos})
+}
+
+func (descr *Description) generic(text string, pos token.Position) {
+	info := Info{Kind: InfoGeneric, Text: text, Position: pos}
+	if pos.IsValid() {
+		info.Pos = pos2str(pos)
+	}
+	*descr = append(*descr, info)
+}
+
+func (descr *Description) field(text string) {
+	*descr = append(*descr, Info{Kind: InfoField, Text: text})
+}
+
+func (descr *Description) pkg(text string) {
+	*descr = append(*descr, Info{Kind: InfoPackage, Text: text})
+}
+
+func (descr *Description) expr(text string) {
+	*descr = append(*descr, Info{Kind: InfoExpr, Text: text})
+}
+
+func (descr *Description) path(text string, pos token.Position, depth int) {
+	*descr = append(*descr, Info{Kind: InfoPath, Text: text, Pos: pos2str(pos), Position: pos, Depth: depth})
+}
+
+func (descr *Description) doc(text string) {
+	*descr = append(*descr, Info{Kind: InfoDoc, Text: text})
+}
+
+func (descr *Description) pos(pos token.Position) {
+	*descr = append(*descr, Info{Kind: InfoPos, Pos: pos2str(pos), Position: pos})
 }
 
 func (info *Info) writeTo(out io.Writer) {
 	switch info.Kind {
-	case InfoErr, InfoObject, InfoSelection, InfoFunction:
+	case InfoErr, InfoObject, InfoSelection, InfoFunction, InfoValue, InfoField, InfoPackage, InfoExpr, InfoPath:
 		out.Write([]byte(info.Text))
 		out.Write([]byte("\n"))
 
-	case InfoTypeContents:
+	case InfoTypeContents, InfoDoc:
 		out.Write([]byte(info.Text))
 
-	case InfoType:
+	case InfoType, InfoImplements, InfoPromotion, InfoGeneric:
 		out.Write([]byte(info.Text))
 		out.Write([]byte("\n"))
 		if info.Pos != "" {
@@ -749,5 +1241,53 @@ func (info *Info) writeTo(out io.Writer) {
 		out.Write([]byte("\n"))
 		out.Write([]byte(info.Pos))
 		out.Write([]byte("\n"))
+	case InfoRef:
+		fmt.Fprintf(out, "\t%d:%d\t%s\n", info.Position.Line, info.Position.Column, info.Text)
+	case InfoCall:
+		fmt.Fprintf(out, "%s%s\t%s:%d:%d\n", strings.Repeat("\t", info.Depth), info.Text, info.Position.Filename, info.Position.Line, info.Position.Column)
 	}
 }
+
+// jsonInfo is the JSON form of Info.
+type jsonInfo struct {
+	Kind  string        `json:"kind"`
+	Text  string        `json:"text,omitempty"`
+	Pos   *jsonPosition `json:"pos,omitempty"`
+	Depth int           `json:"depth,omitempty"`
+}
+
+type jsonPosition struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+}
+
+// MarshalJSON returns the JSON form of info, an object with the name of its
+// kind, its text and its position.
+func (info Info) MarshalJSON() ([]byte, error) {
+	v := jsonInfo{Kind: info.Kind.String(), Text: info.Text, Depth: info.Depth}
+	if info.Position.IsValid() {
+		v.Pos = &jsonPosition{
+			File:   info.Position.Filename,
+			Line:   info.Position.Line,
+			Column: info.Position.Column,
+			Offset: info.Position.Offset,
+		}
+	}
+	return json.Marshal(&v)
+}
+
+// WriteJSON writes descr to out as a JSON array.
+func (descr Description) WriteJSON(out io.Writer) error {
+	if descr == nil {
+		descr = Description{}
+	}
+	buf, err := json.MarshalIndent(descr, "", "\t")
+	if err != nil {
+		return err
+	}
+	buf = append(buf, '\n')
+	_, err = out.Write(buf)
+	return err
+}
@@ -0,0 +1,121 @@
+package go2def
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// importSpecOf returns the import spec of pkg whose path is lit.
+func importSpecOf(pkg *packages.Package, lit *ast.BasicLit) *ast.ImportSpec {
+	for _, file := range pkg.Syntax {
+		for _, spec := range file.Imports {
+			if spec.Path == lit {
+				return spec
+			}
+		}
+	}
+	return nil
+}
+
+// importedPkgName returns the object of the package name declared by spec.
+func importedPkgName(pkg *packages.Package, spec *ast.ImportSpec) *types.PkgName {
+	var obj types.Object
+	if spec.Name != nil {
+		obj = pkg.TypesInfo.Defs[spec.Name]
+	} else {
+		obj = pkg.TypesInfo.Implicits[spec]
+	}
+	pkgname, _ := obj.(*types.PkgName)
+	return pkgname
+}
+
+// describePackage adds to the description the import path, name,
+// directory and module of the package imported as name, followed by its
+// documentation. The position is the one of the package clause holding the
+// documentation. Name is empty for the package being described by its own
+// package clause. If the package can not be loaded the position of the
+// import, or of the package clause, is reported instead.
+func describePackage(ctx *context, imported *types.Package, name string, fallback token.Position) {
+	ctx.out.pkg(fmt.Sprintf("package %s %s", imported.Name(), strconv.Quote(imported.Path())))
+	switch name {
+	case ".":
+		ctx.out.pkg("dot import")
+	case "", imported.Name():
+	default:
+		ctx.out.pkg(fmt.Sprintf("imported as %s", name))
+	}
+
+	pkgs, err := ctx.load(decorateConfig(ctx, &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedModule,
+		Dir:  ctx.Wd,
+	}), imported.Path())
+	if err != nil || len(pkgs) == 0 || len(pkgs[0].GoFiles) == 0 {
+		ctx.out.pos(fallback)
+		return
+	}
+	pkg := pkgs[0]
+
+	ctx.out.pkg(fmt.Sprintf("directory: %s", filepath.Dir(pkg.GoFiles[0])))
+	if mod := pkg.Module; mod != nil {
+		switch {
+		case mod.Main:
+			ctx.out.pkg(fmt.Sprintf("module: %s (main module)", mod.Path))
+		case mod.Version != "":
+			ctx.out.pkg(fmt.Sprintf("module: %s %s", mod.Path, mod.Version))
+		default:
+			ctx.out.pkg(fmt.Sprintf("module: %s", mod.Path))
+		}
+		if rep := mod.Replace; rep != nil {
+			ctx.out.pkg(strings.TrimSpace(fmt.Sprintf("replaced by: %s %s", rep.Path, rep.Version)))
+		}
+	}
+
+	doc, pos := packageDoc(ctx, pkg.GoFiles)
+	if doc != "" {
+		ctx.out.doc(doc)
+	}
+	ctx.out.pos(pos)
+}
+
+// packageDoc returns the package documentation found in files and the
+// position of the package clause it is attached to. If no file has a
+// package documentation the package clause of the first file is returned.
+func packageDoc(ctx *context, files []string) (string, token.Position) {
+	fset := token.NewFileSet()
+	var first token.Position
+	for _, filename := range files {
+		var src interface{}
+		if buf, modified := ctx.Modfiles[filename]; modified {
+			src = buf
+		}
+		file, err := parser.ParseFile(fset, filename, src, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		pos := fset.Position(file.Package)
+		if !first.IsValid() {
+			first = pos
+		}
+		if file.Doc != nil {
+			return file.Doc.Text(), pos
+		}
+	}
+	return "", first
+}
+
+// identPkgName returns the package name used or declared by id, if any.
+func identPkgName(pkg *packages.Package, id *ast.Ident) *types.PkgName {
+	if pkgname, ok := pkg.TypesInfo.Uses[id].(*types.PkgName); ok {
+		return pkgname
+	}
+	pkgname, _ := pkg.TypesInfo.Defs[id].(*types.PkgName)
+	return pkgname
+}
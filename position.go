@@ -0,0 +1,119 @@
+package go2def
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"unicode/utf8"
+)
+
+// ColumnUnit is the unit used to count columns.
+type ColumnUnit uint8
+
+const (
+	ColumnByte  ColumnUnit = iota // columns are byte offsets
+	ColumnRune                    // columns are counted in unicode code points
+	ColumnUTF16                   // columns are counted in UTF-16 code units, like LSP clients do
+)
+
+// ParseColumnUnit parses the name of a column unit: "byte", "rune" or "utf16".
+func ParseColumnUnit(name string) (ColumnUnit, error) {
+	switch name {
+	case "byte":
+		return ColumnByte, nil
+	case "rune":
+		return ColumnRune, nil
+	case "utf16":
+		return ColumnUTF16, nil
+	default:
+		return ColumnByte, fmt.Errorf("unknown column unit %q", name)
+	}
+}
+
+// Offset converts a line and column, both starting at 1, into a byte offset
+// into the file at path. If the file appears in modfiles its modified
+// contents are used.
+func Offset(path string, line, col int, unit ColumnUnit, modfiles map[string][]byte) (int, error) {
+	buf, ok := modfiles[path]
+	if !ok {
+		var err error
+		buf, err = ioutil.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return LineColumnToOffset(buf, line, col, unit)
+}
+
+// LineColumnToOffset converts a line and column, both starting at 1, into a
+// byte offset into buf.
+func LineColumnToOffset(buf []byte, line, col int, unit ColumnUnit) (int, error) {
+	if line < 1 || col < 1 {
+		return 0, fmt.Errorf("invalid position %d:%d", line, col)
+	}
+	off, ok := lineOffset(buf, line)
+	if !ok {
+		return 0, fmt.Errorf("line %d out of range", line)
+	}
+	for col--; col > 0; {
+		if off >= len(buf) || buf[off] == '\n' {
+			return 0, fmt.Errorf("column out of range at line %d", line)
+		}
+		r, sz := utf8.DecodeRune(buf[off:])
+		switch unit {
+		case ColumnByte:
+			sz = 1
+			col--
+		case ColumnRune:
+			col--
+		case ColumnUTF16:
+			col -= utf16Len(r)
+		}
+		off += sz
+	}
+	return off, nil
+}
+
+// Column converts col, a column counted in bytes and starting at 1, into a
+// column counted using unit.
+func Column(buf []byte, line, col int, unit ColumnUnit) int {
+	if unit == ColumnByte {
+		return col
+	}
+	off, ok := lineOffset(buf, line)
+	if !ok {
+		return col
+	}
+	end := off + col - 1
+	r := 1
+	for off < end && off < len(buf) {
+		ch, sz := utf8.DecodeRune(buf[off:])
+		if unit == ColumnUTF16 {
+			r += utf16Len(ch)
+		} else {
+			r++
+		}
+		off += sz
+	}
+	return r
+}
+
+// lineOffset returns the offset of the start of line into buf.
+func lineOffset(buf []byte, line int) (int, bool) {
+	off := 0
+	for i := 1; i < line; i++ {
+		nl := bytes.IndexByte(buf[off:], '\n')
+		if nl < 0 {
+			return 0, false
+		}
+		off += nl + 1
+	}
+	return off, true
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
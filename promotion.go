@@ -0,0 +1,78 @@
+package go2def
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// selectionOf returns the selector expression that selects id, if any, and
+// its selection.
+func selectionOf(pkg *packages.Package, id *ast.Ident) (*ast.SelectorExpr, *types.Selection) {
+	for expr, sel := range pkg.TypesInfo.Selections {
+		if expr.Sel == id {
+			return expr, sel
+		}
+	}
+	return nil, nil
+}
+
+// describePromotion adds to the description the path through embedded
+// fields that expr follows to reach a promoted field or method, for example
+// a.Inner.(*Base).Method, followed by the declaration of each embedded field
+// along the path.
+func describePromotion(ctx *context, expr *ast.SelectorExpr, sel *types.Selection) {
+	index := sel.Index()
+	if len(index) <= 1 {
+		return
+	}
+
+	embedded, owners := embeddedFields(sel.Recv(), index)
+	if embedded == nil {
+		return
+	}
+	path := printerSprint(ctx.getFileSet(expr.Pos()), expr.X) + "." + embeddingPath(embedded) + "." + sel.Obj().Name()
+
+	ctx.out.promotion("promoted: "+path, ctx.position(sel.Obj().Pos()))
+	for i, f := range embedded {
+		ctx.out.promotion(fmt.Sprintf("embedded %s in %s", printTypesTypeNice(f.Type()), printTypesTypeNice(owners[i])), ctx.position(f.Pos()))
+	}
+}
+
+// embeddedFields returns the embedded fields traversed by the selection path
+// index (as returned by types.Selection.Index), starting from recv, and the
+// types containing each of them.
+func embeddedFields(recv types.Type, index []int) (embedded []*types.Var, owners []types.Type) {
+	t := recv
+	for _, idx := range index[:len(index)-1] {
+		if ptyp, isptr := t.(*types.Pointer); isptr {
+			t = ptyp.Elem()
+		}
+		st, isstruct := t.Underlying().(*types.Struct)
+		if !isstruct {
+			return nil, nil
+		}
+		f := st.Field(idx)
+		embedded = append(embedded, f)
+		owners = append(owners, t)
+		t = f.Type()
+	}
+	return embedded, owners
+}
+
+// embeddingPath formats a list of embedded fields as a selector path, fields
+// embedded as pointers are written as (*Name).
+func embeddingPath(embedded []*types.Var) string {
+	path := make([]string, len(embedded))
+	for i, f := range embedded {
+		if _, isptr := f.Type().(*types.Pointer); isptr {
+			path[i] = "(*" + f.Name() + ")"
+		} else {
+			path[i] = f.Name()
+		}
+	}
+	return strings.Join(path, ".")
+}
@@ -0,0 +1,407 @@
+package go2def
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aarzilli/go2def/visit"
+	"golang.org/x/tools/go/packages"
+)
+
+const (
+	recordingManifest = "manifest.json"
+	recordingFiles    = "files"
+
+	// replayEnv is the environment variable used to tell a process that it was
+	// started as the packages driver of a replayed query, its value is the
+	// directory where the recording was extracted.
+	replayEnv = "GO2DEF_REPLAY"
+)
+
+// Recording contains all the inputs of a query, so that it can be replayed
+// later, without access to the original environment, to reproduce a bad
+// answer.
+type Recording struct {
+	Path string // path of the file the query was executed on
+	Pos  [2]int // position of the query
+	Wd   string // working directory used to load packages
+
+	Env       []string // environment variables that affect loading packages
+	BuildTags []string // build flags returned by findBuildTags
+	Goos      string   // GOOS selected by decorateConfig
+	Goarch    string   // GOARCH selected by decorateConfig
+
+	Modfiles map[string][]byte // modified files
+
+	Loads []RecordedLoad // every call to packages.Load in the order they were made
+
+	files map[string]bool // files that need to be saved in the archive
+	dir   string          // directory where the recording was extracted
+	err   error           // first error encountered while recording
+}
+
+// RecordedLoad is the result of a call to packages.Load in the format used by
+// go/packages drivers. Module information isn't part of that format and is
+// saved separately.
+type RecordedLoad struct {
+	Patterns []string
+	Response json.RawMessage
+	Modules  map[string]*packages.Module `json:",omitempty"` // module of each package, by ID
+}
+
+// driverResponse is the response of a go/packages driver, see the
+// documentation of golang.org/x/tools/go/packages.
+type driverResponse struct {
+	NotHandled bool
+	Sizes      *types.StdSizes
+	Roots      []string `json:",omitempty"`
+	Packages   []*packages.Package
+}
+
+func (rec *Recording) start(ctx *context, path string, pos [2]int) {
+	rec.Path = path
+	rec.Pos = pos
+	rec.Wd = ctx.Wd
+	rec.Modfiles = ctx.Modfiles
+	rec.files = make(map[string]bool)
+	rec.files[path] = true
+}
+
+func (rec *Recording) finish(ctx *context) {
+	rec.BuildTags = ctx.buildTags
+	rec.Goos, rec.Goarch = ctx.goos, ctx.goarch
+}
+
+// addLoad saves the result of a call to packages.Load.
+func (rec *Recording) addLoad(cfg *packages.Config, patterns []string, pkgs []*packages.Package) {
+	if rec.Env == nil {
+		rec.Env = loadEnv(cfg.Env)
+	}
+
+	resp := driverResponse{}
+	for _, pkg := range pkgs {
+		resp.Roots = append(resp.Roots, pkg.ID)
+	}
+	modules := make(map[string]*packages.Module)
+
+	pkgit := visit.Packages(pkgs)
+	for pkgit.Next() {
+		pkg := pkgit.Pkg()
+		if pkg == nil {
+			continue
+		}
+		if sizes, ok := pkg.TypesSizes.(*types.StdSizes); ok && resp.Sizes == nil {
+			resp.Sizes = sizes
+		}
+		resp.Packages = append(resp.Packages, pkg)
+		if pkg.Module != nil {
+			modules[pkg.ID] = pkg.Module
+		}
+		for _, files := range [][]string{pkg.GoFiles, pkg.CompiledGoFiles, pkg.OtherFiles} {
+			for _, filename := range files {
+				rec.files[filename] = true
+			}
+		}
+		if pkg.ExportFile != "" {
+			rec.files[pkg.ExportFile] = true
+		}
+	}
+
+	buf, err := json.Marshal(&resp)
+	if err != nil {
+		if rec.err == nil {
+			rec.err = fmt.Errorf("could not record load of %q: %v", patterns, err)
+		}
+		return
+	}
+	rec.Loads = append(rec.Loads, RecordedLoad{Patterns: patterns, Response: buf, Modules: modules})
+}
+
+// restoreModules sets the module of the packages returned by the replayed
+// load of patterns, which the packages driver can not return.
+func (rec *Recording) restoreModules(patterns []string, pkgs []*packages.Package) {
+	load := rec.findLoad(patterns)
+	if load == nil || len(load.Modules) == 0 {
+		return
+	}
+	pkgit := visit.Packages(pkgs)
+	for pkgit.Next() {
+		if pkg := pkgit.Pkg(); pkg != nil && pkg.Module == nil {
+			pkg.Module = load.Modules[pkg.ID]
+		}
+	}
+}
+
+// findLoad returns the recorded load of patterns, which can contain paths
+// where the recording was extracted.
+func (rec *Recording) findLoad(patterns []string) *RecordedLoad {
+	prefix := rec.localPath("")
+	orig := make([]string, len(patterns))
+	for i := range patterns {
+		orig[i] = strings.Replace(patterns[i], prefix, "", 1)
+	}
+	for i := range rec.Loads {
+		if sameStrings(rec.Loads[i].Patterns, orig) {
+			return &rec.Loads[i]
+		}
+	}
+	return nil
+}
+
+// loadEnvVars are the environment variables, other than the ones starting
+// with GO or CGO_, that can change the result of packages.Load.
+var loadEnvVars = map[string]bool{
+	"PATH": true, "HOME": true, "TMPDIR": true,
+	"XDG_CACHE_HOME": true, "XDG_CONFIG_HOME": true,
+	"USERPROFILE": true, "APPDATA": true, "LOCALAPPDATA": true,
+	"CC": true, "CXX": true, "PKG_CONFIG": true,
+}
+
+// loadEnv returns the variables of env that can change the result of
+// packages.Load, everything else (credentials for example) should not end up
+// in a recording. User names and passwords are removed from GOPROXY.
+func loadEnv(env []string) []string {
+	r := []string{}
+	for _, kv := range env {
+		name := kv
+		if eq := strings.Index(kv, "="); eq >= 0 {
+			name = kv[:eq]
+		}
+		switch {
+		case name == "GOPROXY":
+			r = append(r, "GOPROXY="+redactProxies(kv[len("GOPROXY="):]))
+		case strings.HasPrefix(name, "GO"), strings.HasPrefix(name, "CGO_"), loadEnvVars[name]:
+			r = append(r, kv)
+		}
+	}
+	return r
+}
+
+// redactProxies removes the user information from the URLs of a GOPROXY
+// list.
+func redactProxies(proxies string) string {
+	var b strings.Builder
+	for proxies != "" {
+		i := strings.IndexAny(proxies, ",|")
+		if i < 0 {
+			i = len(proxies)
+		} else {
+			i++ // keep the separator
+		}
+		entry := proxies[:i]
+		proxies = proxies[i:]
+		if u, err := url.Parse(strings.TrimRight(entry, ",|")); err == nil && u.User != nil {
+			u.User = nil
+			entry = u.String() + entry[len(strings.TrimRight(entry, ",|")):]
+		}
+		b.WriteString(entry)
+	}
+	return b.String()
+}
+
+// Save writes the recording, including the contents of all the files needed
+// to load its packages, as a tar archive. An error is returned, after writing
+// the archive, if a load could not be recorded.
+func (rec *Recording) Save(w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	manifest, err := json.MarshalIndent(rec, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, recordingManifest, manifest); err != nil {
+		return err
+	}
+
+	for filename := range rec.files {
+		buf, err := ioutil.ReadFile(filename)
+		if err != nil {
+			continue
+		}
+		if err := writeTarFile(tw, path.Join(recordingFiles, filepath.ToSlash(filename)), buf); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return rec.err
+}
+
+func writeTarFile(tw *tar.Writer, name string, buf []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: int64(len(buf)),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(buf)
+	return err
+}
+
+// ExtractRecording reads a recording written by Save, extracting its
+// files into dir.
+func ExtractRecording(r io.Reader, dir string) (*Recording, error) {
+	tr := tar.NewReader(r)
+	var rec *Recording
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if strings.HasPrefix(name, "..") || filepath.IsAbs(name) {
+			return nil, fmt.Errorf("bad file name in recording %q", hdr.Name)
+		}
+		buf, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if name == recordingManifest {
+			rec = &Recording{}
+			if err := json.Unmarshal(buf, rec); err != nil {
+				return nil, err
+			}
+		}
+		dest := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(dest, buf, 0644); err != nil {
+			return nil, err
+		}
+	}
+	if rec == nil {
+		return nil, fmt.Errorf("no manifest in recording")
+	}
+	rec.dir = dir
+	os.MkdirAll(rec.localPath(rec.Wd), 0755)
+	return rec, nil
+}
+
+// localPath returns the path where the file at path was extracted.
+func (rec *Recording) localPath(filename string) string {
+	return filepath.Join(rec.dir, recordingFiles, filename)
+}
+
+// Replay executes again the query saved in rec, which must have been read by
+// ExtractRecording. Packages are loaded using a stand-in driver, fed from the
+// recorded data, that is the current executable, which must call
+// RunReplayDriver at startup.
+func Replay(rec *Recording, cfg *Config) Description {
+	exe, err := os.Executable()
+	if err != nil {
+		var out Description
+		out.err("could not find executable: %v", err)
+		return out
+	}
+
+	out := io.Writer(os.Stdout)
+	if cfg != nil && cfg.Out != nil {
+		out = cfg.Out
+	}
+
+	var buf bytes.Buffer
+
+	ctx := newContext(rec.localPath(rec.Path), cfg)
+	ctx.Out = &buf
+	ctx.Wd = rec.localPath(rec.Wd)
+	ctx.buildTags = rec.BuildTags
+	ctx.env = append(append([]string{}, rec.Env...), "GOPACKAGESDRIVER="+exe, replayEnv+"="+rec.dir)
+	ctx.Record = nil
+	ctx.replay = rec
+	if rec.Modfiles != nil {
+		ctx.Modfiles = make(map[string][]byte)
+		for filename, buf := range rec.Modfiles {
+			ctx.Modfiles[rec.localPath(filename)] = buf
+		}
+	}
+
+	descr := describe(ctx, ctx.originalPath, rec.Pos)
+
+	// restore original file names
+	prefix := rec.localPath("")
+	for i := range descr {
+		descr[i].Text = strings.Replace(descr[i].Text, prefix, "", -1)
+		descr[i].Pos = strings.Replace(descr[i].Pos, prefix, "", -1)
+		descr[i].Position.Filename = strings.Replace(descr[i].Position.Filename, prefix, "", -1)
+	}
+	out.Write([]byte(strings.Replace(buf.String(), prefix, "", -1)))
+
+	return descr
+}
+
+// RunReplayDriver checks whether the current process was started as the
+// packages driver of a query executed by Replay, if it was it answers the
+// driver request and returns true.
+func RunReplayDriver() bool {
+	dir := os.Getenv(replayEnv)
+	if dir == "" {
+		return false
+	}
+
+	if err := replayDriver(dir, os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "replay driver: %v\n", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+func replayDriver(dir string, patterns []string, out io.Writer) error {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, recordingManifest))
+	if err != nil {
+		return err
+	}
+	rec := &Recording{dir: dir}
+	if err := json.Unmarshal(buf, rec); err != nil {
+		return err
+	}
+
+	load := rec.findLoad(patterns)
+	if load == nil {
+		return fmt.Errorf("no recorded load for %q", patterns)
+	}
+	var resp driverResponse
+	if err := json.Unmarshal(load.Response, &resp); err != nil {
+		return err
+	}
+	for _, pkg := range resp.Packages {
+		for _, files := range [][]string{pkg.GoFiles, pkg.CompiledGoFiles, pkg.OtherFiles} {
+			for i := range files {
+				files[i] = rec.localPath(files[i])
+			}
+		}
+		if pkg.ExportFile != "" {
+			pkg.ExportFile = rec.localPath(pkg.ExportFile)
+		}
+	}
+	return json.NewEncoder(out).Encode(&resp)
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package go2def
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// References lists every use and definition of the object at the specified
+// position in all the packages (including tests) of the module containing
+// path. The references are sorted and grouped by file.
+func References(path string, pos [2]int, cfg *Config) Description {
+	ctx := newContext(path, cfg)
+
+	_, obj, err := objectAt(ctx, path, pos, true)
+	if err != nil {
+		ctx.out.err("%v", err)
+		ctx.writeOut(true)
+		return ctx.out
+	}
+
+	refs := findReferences(ctx, ctx.objKey(obj))
+
+	files := make(map[string][]byte)
+	for _, ref := range refs {
+		ctx.out.ref(ref, ctx.sourceLine(ref, files))
+	}
+
+	ctx.writeOut(len(refs) > 0)
+	return ctx.out
+}
+
+// findReferences returns the positions of all the identifiers, in the
+// workspace, that refer to (or define) the object identified by key, sorted
+// by file and offset.
+func findReferences(ctx *context, key objKey) []token.Position {
+	seen := make(map[token.Position]bool)
+	refs := []token.Position{}
+	add := func(id *ast.Ident) {
+		pos := ctx.position(id.Pos())
+		if !seen[pos] {
+			seen[pos] = true
+			refs = append(refs, pos)
+		}
+	}
+
+	workspacePackages(ctx, func(pkg *packages.Package) {
+		for id, obj := range pkg.TypesInfo.Defs {
+			if obj != nil && ctx.objKey(obj) == key {
+				add(id)
+			}
+		}
+		for id, obj := range pkg.TypesInfo.Uses {
+			if ctx.objKey(obj) == key {
+				add(id)
+			}
+		}
+	})
+
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Filename != refs[j].Filename {
+			return refs[i].Filename < refs[j].Filename
+		}
+		return refs[i].Offset < refs[j].Offset
+	})
+
+	return refs
+}
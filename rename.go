@@ -0,0 +1,383 @@
+package go2def
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Edit replaces Length bytes at Offset in file Filename with Text.
+type Edit struct {
+	Filename string `json:"file"`
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"` // column, in bytes, of Offset
+	Text     string `json:"text"`
+}
+
+// Rename returns the edits needed to rename the object at the specified
+// position to newName, in all the packages (including tests) of the module
+// containing path. The edits are sorted by file and offset.
+// An error is returned, without edits, if the renaming would not compile or
+// would change the meaning of the program, for example because a renamed
+// identifier would be shadowed by a different declaration or a type would no
+// longer implement an interface.
+func Rename(path string, pos [2]int, newName string, cfg *Config) ([]Edit, error) {
+	ctx := newContext(path, cfg)
+
+	if !token.IsIdentifier(newName) || newName == "_" {
+		return nil, fmt.Errorf("invalid identifier %q", newName)
+	}
+
+	_, obj, err := objectAt(ctx, path, pos, true)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Name() == newName {
+		return nil, fmt.Errorf("%s is already called %s", obj.Name(), newName)
+	}
+
+	r := &renamer{ctx: ctx, obj: obj, key: ctx.objKey(obj), newName: newName}
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+
+	declPos := ctx.position(obj.Pos())
+	refs := findReferences(ctx, r.key)
+	found := false
+	for _, ref := range refs {
+		if ref == declPos {
+			found = true
+		}
+	}
+	if !found {
+		// the symbol of a type switch does not define an object, the
+		// objects of its case clauses are declared at its position
+		refs = append(refs, declPos)
+	}
+
+	edits := make([]Edit, 0, len(refs))
+	for _, ref := range refs {
+		edits = append(edits, Edit{Filename: ref.Filename, Offset: ref.Offset, Length: len(obj.Name()), Line: ref.Line, Column: ref.Column, Text: newName})
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		if edits[i].Filename != edits[j].Filename {
+			return edits[i].Filename < edits[j].Filename
+		}
+		return edits[i].Offset < edits[j].Offset
+	})
+	return edits, nil
+}
+
+// ApplyEdits returns a copy of buf with edits applied. The edits must belong
+// to the same file and be sorted by offset.
+func ApplyEdits(buf []byte, edits []Edit) []byte {
+	r := make([]byte, 0, len(buf))
+	last := 0
+	for _, edit := range edits {
+		r = append(r, buf[last:edit.Offset]...)
+		r = append(r, edit.Text...)
+		last = edit.Offset + edit.Length
+	}
+	return append(r, buf[last:]...)
+}
+
+// renamer checks whether renaming obj to newName is safe.
+type renamer struct {
+	ctx       *context
+	obj       types.Object
+	key       objKey
+	newName   string
+	conflicts []string
+}
+
+func (r *renamer) conflict(pos token.Pos, fmtstr string, args ...interface{}) {
+	msg := fmt.Sprintf(fmtstr, args...)
+	if pos.IsValid() {
+		msg = pos2str(r.ctx.position(pos)) + ": " + msg
+	}
+	for _, c := range r.conflicts {
+		if c == msg {
+			return
+		}
+	}
+	r.conflicts = append(r.conflicts, msg)
+}
+
+func (r *renamer) check() error {
+	obj := r.obj
+	switch obj := obj.(type) {
+	case *types.PkgName:
+		return fmt.Errorf("renaming imports is not supported")
+	case *types.Var:
+		if obj.Anonymous() {
+			return fmt.Errorf("cannot rename embedded field %s, rename its type instead", obj.Name())
+		}
+	}
+	if obj.Pkg() == nil {
+		return fmt.Errorf("cannot rename predeclared identifier %s", obj.Name())
+	}
+
+	declPkg := r.declaringPackage()
+	if declPkg == nil {
+		return fmt.Errorf("cannot rename %s, it is not declared in the module", obj.Name())
+	}
+
+	if obj.Exported() && !token.IsExported(r.newName) {
+		r.checkExport()
+	}
+
+	switch obj := obj.(type) {
+	case *types.Func:
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			r.checkMethod(declPkg, obj, recv.Type())
+		} else {
+			r.checkLexical()
+		}
+	case *types.Var:
+		if obj.IsField() {
+			r.checkField(declPkg, obj)
+		} else {
+			r.checkLexical()
+		}
+	case *types.TypeName:
+		r.checkEmbedded()
+		r.checkLexical()
+	default:
+		r.checkLexical()
+	}
+
+	if len(r.conflicts) > 0 {
+		return fmt.Errorf("renaming %s to %s would cause conflicts:\n\t%s", obj.Name(), r.newName, strings.Join(r.conflicts, "\n\t"))
+	}
+	return nil
+}
+
+// declaringPackage returns a package of the workspace that contains the
+// declaration of r.obj.
+func (r *renamer) declaringPackage() *packages.Package {
+	var declPkg *packages.Package
+	workspacePackages(r.ctx, func(pkg *packages.Package) {
+		if declPkg != nil || r.ctx.isStdlib(pkg) {
+			return
+		}
+		if len(r.declared(pkg)) > 0 {
+			declPkg = pkg
+		}
+	})
+	return declPkg
+}
+
+// declared returns the objects of pkg that are declarations of r.obj. The
+// symbol of a type switch declares a different object in each case clause.
+func (r *renamer) declared(pkg *packages.Package) []types.Object {
+	objs := []types.Object{}
+	for _, obj := range pkg.TypesInfo.Defs {
+		if obj != nil && r.ctx.objKey(obj) == r.key {
+			objs = append(objs, obj)
+		}
+	}
+	for _, obj := range pkg.TypesInfo.Implicits {
+		if _, isvar := obj.(*types.Var); isvar && r.ctx.objKey(obj) == r.key {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// checkExport checks that an exported object isn't used outside of its
+// package.
+func (r *renamer) checkExport() {
+	workspacePackages(r.ctx, func(pkg *packages.Package) {
+		if pkg.Types.Path() == r.obj.Pkg().Path() {
+			return
+		}
+		for id, obj := range pkg.TypesInfo.Uses {
+			if r.ctx.objKey(obj) == r.key {
+				r.conflict(id.Pos(), "%s would become unexported but is used in package %s", r.obj.Name(), pkg.Types.Path())
+			}
+		}
+	})
+}
+
+// checkLexical checks that the renamed object does not conflict with other
+// declarations of its scope, that none of its references would be shadowed by
+// a declaration of newName and that no reference to a different object
+// called newName would refer to the renamed object.
+func (r *renamer) checkLexical() {
+	workspacePackages(r.ctx, func(pkg *packages.Package) {
+		// the object is used by packages that don't declare it only with
+		// references qualified by a package name
+		for _, obj := range r.declared(pkg) {
+			r.checkScope(pkg, obj.Parent())
+		}
+	})
+}
+
+// checkScope does the checks of checkLexical for the declaration of the
+// renamed object in declScope.
+func (r *renamer) checkScope(pkg *packages.Package, declScope *types.Scope) {
+	if other := declScope.Lookup(r.newName); other != nil {
+		r.conflict(other.Pos(), "%s is already declared in this scope", r.newName)
+	}
+	if declScope == pkg.Types.Scope() {
+		for i := 0; i < declScope.NumChildren(); i++ {
+			if other := declScope.Child(i).Lookup(r.newName); other != nil {
+				r.conflict(other.Pos(), "%s conflicts with the package-level declaration of %s", r.newName, r.obj.Name())
+			}
+		}
+	}
+
+	for id, obj := range pkg.TypesInfo.Uses {
+		switch {
+		case r.ctx.objKey(obj) == r.key:
+			scope := pkg.Types.Scope().Innermost(id.Pos())
+			if scope == nil {
+				continue
+			}
+			if scope2, other := scope.LookupParent(r.newName, id.Pos()); other != nil && isInnerScope(scope2, declScope) {
+				r.conflict(id.Pos(), "reference to %s would be shadowed by %s declared at %s", r.obj.Name(), r.newName, pos2str(r.ctx.position(other.Pos())))
+			}
+		case id.Name == r.newName && obj.Parent() != nil:
+			scope := pkg.Types.Scope().Innermost(id.Pos())
+			if scope == nil || (scope != declScope && !isInnerScope(scope, declScope)) {
+				continue
+			}
+			if declScope != pkg.Types.Scope() && id.Pos() < r.obj.Pos() {
+				// local declarations are only visible after they are declared
+				continue
+			}
+			if obj.Parent() != declScope && !isInnerScope(obj.Parent(), declScope) {
+				r.conflict(id.Pos(), "reference to %s declared at %s would refer to the renamed %s", r.newName, pos2str(r.ctx.position(obj.Pos())), r.obj.Name())
+			}
+		}
+	}
+}
+
+// isInnerScope returns true if scope is nested inside outer.
+func isInnerScope(scope, outer *types.Scope) bool {
+	for s := scope.Parent(); s != nil; s = s.Parent() {
+		if s == outer {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEmbedded checks that the renamed type isn't embedded in a struct,
+// which would also change the name of a field.
+func (r *renamer) checkEmbedded() {
+	workspacePackages(r.ctx, func(pkg *packages.Package) {
+		for id, obj := range pkg.TypesInfo.Defs {
+			v, ok := obj.(*types.Var)
+			if !ok || !v.Anonymous() {
+				continue
+			}
+			typ := v.Type()
+			if ptr, isptr := typ.(*types.Pointer); isptr {
+				typ = ptr.Elem()
+			}
+			if named, isnamed := typ.(*types.Named); isnamed && r.ctx.objKey(named.Obj()) == r.key {
+				r.conflict(id.Pos(), "%s is embedded in a struct, renaming it would rename the field", r.obj.Name())
+			}
+		}
+	})
+}
+
+// checkField checks that no field or method of the struct containing field
+// is already called newName.
+func (r *renamer) checkField(pkg *packages.Package, field *types.Var) {
+	for _, tv := range pkg.TypesInfo.Types {
+		st, ok := tv.Type.(*types.Struct)
+		if !ok || !structHasField(st, field) {
+			continue
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			if st.Field(i).Name() == r.newName {
+				r.conflict(st.Field(i).Pos(), "field %s already exists", r.newName)
+			}
+		}
+	}
+	for _, obj := range pkg.TypesInfo.Defs {
+		tn, ok := obj.(*types.TypeName)
+		if !ok {
+			continue
+		}
+		st, ok := tn.Type().Underlying().(*types.Struct)
+		if !ok || !structHasField(st, field) {
+			continue
+		}
+		if m, _, _ := types.LookupFieldOrMethod(types.NewPointer(tn.Type()), false, tn.Pkg(), r.newName); m != nil {
+			if _, ismethod := m.(*types.Func); ismethod {
+				r.conflict(m.Pos(), "%s already has a method %s", tn.Name(), r.newName)
+			}
+		}
+	}
+}
+
+func structHasField(st *types.Struct, field *types.Var) bool {
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i) == field {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMethod checks that the receiver type of method doesn't already have a
+// field or method called newName and that renaming method does not break the
+// implementation of an interface.
+func (r *renamer) checkMethod(pkg *packages.Package, method *types.Func, recv types.Type) {
+	if ptr, isptr := recv.(*types.Pointer); isptr {
+		recv = ptr.Elem()
+	}
+	named, _ := recv.(*types.Named)
+
+	if iface, isiface := recv.Underlying().(*types.Interface); isiface {
+		for i := 0; i < iface.NumMethods(); i++ {
+			if iface.Method(i).Name() == r.newName {
+				r.conflict(iface.Method(i).Pos(), "method %s already exists", r.newName)
+			}
+		}
+		if named == nil {
+			return
+		}
+		for _, t := range workspaceNamedTypes(r.ctx) {
+			if types.IsInterface(t) || t.Obj().Pkg() == nil {
+				continue
+			}
+			if types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface) {
+				r.conflict(t.Obj().Pos(), "%s would no longer implement %s", printTypesTypeNice(t), printTypesTypeNice(named))
+			}
+		}
+		return
+	}
+
+	if obj, index, _ := types.LookupFieldOrMethod(types.NewPointer(recv), false, pkg.Types, r.newName); obj != nil && len(index) == 1 {
+		r.conflict(obj.Pos(), "%s already has a field or method %s", printTypesTypeNice(recv), r.newName)
+	}
+
+	if named == nil {
+		return
+	}
+	ptr := types.NewPointer(named)
+	for _, t := range workspaceNamedTypes(r.ctx) {
+		iface, isiface := t.Underlying().(*types.Interface)
+		if !isiface || types.Identical(t, named) {
+			continue
+		}
+		if !types.Implements(ptr, iface) {
+			continue
+		}
+		for i := 0; i < iface.NumMethods(); i++ {
+			if iface.Method(i).Name() == method.Name() {
+				r.conflict(t.Obj().Pos(), "%s would no longer implement %s", printTypesTypeNice(named), printTypesTypeNice(t))
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+//go:build !go1.18
+// +build !go1.18
+
+package go2def
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// isGeneric returns true if named has type parameters and has not been
+// instantiated, always false before go1.18.
+func isGeneric(named *types.Named) bool {
+	return false
+}
+
+// hasTypeParams returns true if typ contains type parameters, always false
+// before go1.18.
+func hasTypeParams(typ types.Type) bool {
+	return false
+}
+
+// describeInstance does nothing before go1.18.
+func describeInstance(ctx *context, pkg *packages.Package, id *ast.Ident) {
+}
+
+// describeSelectionInstance does nothing before go1.18.
+func describeSelectionInstance(ctx *context, sel *types.Selection) {
+}
+
+// describeTypeParam does nothing before go1.18.
+func describeTypeParam(ctx *context, typ types.Type) {
+}
+
+// describeOrigin does nothing before go1.18.
+func describeOrigin(ctx *context, typ types.Type) {
+}
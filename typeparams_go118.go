@@ -0,0 +1,245 @@
+//go:build go1.18
+// +build go1.18
+
+package go2def
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// isGeneric returns true if named has type parameters and has not been
+// instantiated.
+func isGeneric(named *types.Named) bool {
+	return named.TypeParams().Len() > 0 && named.TypeArgs().Len() == 0
+}
+
+// hasTypeParams returns true if typ contains type parameters, either
+// directly or as type arguments of an instantiated type.
+func hasTypeParams(typ types.Type) bool {
+	switch t := typ.(type) {
+	case *types.TypeParam:
+		return true
+	case *types.Pointer:
+		return hasTypeParams(t.Elem())
+	case *types.Slice:
+		return hasTypeParams(t.Elem())
+	case *types.Array:
+		return hasTypeParams(t.Elem())
+	case *types.Chan:
+		return hasTypeParams(t.Elem())
+	case *types.Map:
+		return hasTypeParams(t.Key()) || hasTypeParams(t.Elem())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if hasTypeParams(t.Field(i).Type()) {
+				return true
+			}
+		}
+	case *types.Named:
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			if hasTypeParams(t.TypeArgs().At(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// describeInstance adds the type arguments of id to the description, if id
+// refers to an instance of a generic function or type. For functions the
+// instantiated signature is also added. If id selects a field or method of
+// an instantiated type the instance is described by
+// describeSelectionInstance.
+func describeInstance(ctx *context, pkg *packages.Package, id *ast.Ident) {
+	inst, ok := pkg.TypesInfo.Instances[id]
+	if !ok {
+		if _, sel := selectionOf(pkg, id); sel != nil {
+			describeSelectionInstance(ctx, sel)
+		}
+		return
+	}
+	name := id.Name
+	if obj := pkg.TypesInfo.Uses[id]; obj != nil && obj.Pkg() != nil {
+		name = obj.Pkg().Name() + "." + obj.Name()
+	}
+	args := make([]string, inst.TypeArgs.Len())
+	for i := range args {
+		args[i] = printTypesTypeNice(inst.TypeArgs.At(i))
+	}
+	ctx.out.generic(fmt.Sprintf("instance: %s[%s]", name, strings.Join(args, ", ")), token.Position{})
+	if _, isfunc := inst.Type.(*types.Signature); isfunc {
+		ctx.out.generic("instantiated: "+printTypesTypeNice(inst.Type), token.Position{})
+	}
+}
+
+// describeSelectionInstance adds the instantiated type declaring the field
+// or method selected by sel to the description, followed by the field or
+// method with the type arguments substituted, if the type is an instance of
+// a generic type.
+func describeSelectionInstance(ctx *context, sel *types.Selection) {
+	var recv types.Type
+	switch obj := sel.Obj().(type) {
+	case *types.Func:
+		if r := obj.Type().(*types.Signature).Recv(); r != nil {
+			recv = r.Type()
+		}
+	case *types.Var:
+		if len(sel.Index()) != 1 {
+			// promoted fields are declared by an embedded type
+			return
+		}
+		recv = sel.Recv()
+	}
+	named, isnamed := derefNamed(recv)
+	if !isnamed || named.TypeArgs().Len() == 0 || hasTypeParams(named) {
+		return
+	}
+	ctx.out.generic("instance: "+printTypesTypeNice(named), token.Position{})
+	ctx.out.generic("instantiated: "+printTypesObjectNice(sel.Obj()), token.Position{})
+}
+
+// describeTypeParam adds the constraint of typ, if it is a type parameter,
+// and its type set to the description.
+func describeTypeParam(ctx *context, typ types.Type) {
+	tparam, ok := typ.(*types.TypeParam)
+	if !ok {
+		return
+	}
+	constraint := tparam.Constraint()
+	pos := token.Position{}
+	if named, isnamed := constraint.(*types.Named); isnamed && named.Obj().Pkg() != nil {
+		pos = ctx.position(named.Obj().Pos())
+	}
+	ctx.out.generic("constraint: "+printTypesTypeNice(constraint), pos)
+
+	iface, ok := constraint.Underlying().(*types.Interface)
+	if !ok {
+		return
+	}
+	ctx.out.generic("type set: "+typeSetString(iface), token.Position{})
+	if iface.NumMethods() > 0 {
+		ms := make([]string, iface.NumMethods())
+		for i := range ms {
+			m := iface.Method(i)
+			ms[i] = m.Name() + strings.TrimPrefix(printTypesTypeNice(m.Type()), "func")
+		}
+		ctx.out.generic("methods: "+strings.Join(ms, "; "), token.Position{})
+	}
+}
+
+// describeOrigin adds the generic declaration of typ to the description, if
+// typ is an instantiated named type.
+func describeOrigin(ctx *context, typ types.Type) {
+	named, isnamed := typ.(*types.Named)
+	if !isnamed || named.TypeArgs().Len() == 0 {
+		return
+	}
+	origin := named.Origin()
+	ctx.out.generic("generic: "+printTypesTypeNice(origin), ctx.position(origin.Obj().Pos()))
+}
+
+// typeSetString describes the normalized type set of the constraint iface.
+func typeSetString(iface *types.Interface) string {
+	terms, all := typeSetTerms(iface)
+	switch {
+	case all && iface.IsComparable():
+		return "comparable types"
+	case all:
+		return "all types"
+	case len(terms) == 0:
+		return "empty"
+	}
+	s := make([]string, len(terms))
+	for i, term := range terms {
+		s[i] = printTypesTypeNice(term.Type())
+		if term.Tilde() {
+			s[i] = "~" + s[i]
+		}
+	}
+	return strings.Join(s, " | ")
+}
+
+// typeSetTerms returns the terms of the type set of iface, the intersection
+// of the type sets of its embedded elements. If all is true the type set
+// contains all types (ignoring methods).
+func typeSetTerms(iface *types.Interface) (terms []*types.Term, all bool) {
+	all = true
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		var eterms []*types.Term
+		eall := false
+		switch e := iface.EmbeddedType(i).(type) {
+		case *types.Union:
+			for j := 0; j < e.Len(); j++ {
+				term := e.Term(j)
+				if it, isiface := term.Type().Underlying().(*types.Interface); isiface {
+					sub, suball := typeSetTerms(it)
+					eall = eall || suball
+					eterms = append(eterms, sub...)
+				} else {
+					eterms = append(eterms, term)
+				}
+			}
+		default:
+			if it, isiface := e.Underlying().(*types.Interface); isiface {
+				eterms, eall = typeSetTerms(it)
+			} else {
+				eterms = []*types.Term{types.NewTerm(false, e)}
+			}
+		}
+		switch {
+		case eall:
+			// does not restrict the type set
+		case all:
+			terms, all = eterms, false
+		default:
+			terms = intersectTerms(terms, eterms)
+		}
+	}
+	return terms, all
+}
+
+func intersectTerms(a, b []*types.Term) []*types.Term {
+	r := []*types.Term{}
+	for _, x := range a {
+		for _, y := range b {
+			t := intersectTerm(x, y)
+			if t == nil {
+				continue
+			}
+			dup := false
+			for _, t2 := range r {
+				if t2.Tilde() == t.Tilde() && types.Identical(t2.Type(), t.Type()) {
+					dup = true
+				}
+			}
+			if !dup {
+				r = append(r, t)
+			}
+		}
+	}
+	return r
+}
+
+func intersectTerm(x, y *types.Term) *types.Term {
+	switch {
+	case x.Tilde() && y.Tilde(), !x.Tilde() && !y.Tilde():
+		if types.Identical(x.Type(), y.Type()) {
+			return x
+		}
+	case x.Tilde():
+		if types.Identical(y.Type().Underlying(), x.Type()) {
+			return y
+		}
+	case y.Tilde():
+		if types.Identical(x.Type().Underlying(), y.Type()) {
+			return x
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+//go:build go1.18
+// +build go1.18
+
+package go2def
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// genericFixture is written to a temporary module, since generics can not
+// be used by the packages of this module.
+const genericFixture = `package testfixture8
+
+import "fmt"
+
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+type Signed interface {
+	~int | ~int8 | ~int64
+}
+
+type SignedNumber interface {
+	Number
+	Signed
+}
+
+func Map[T, U any](xs []T, f func(T) U) []U {
+	r := make([]U, 0, len(xs))
+	for _, x := range xs {
+		r = append(r, f(x))
+	}
+	return r
+}
+
+func Sum[N SignedNumber](xs []N) N {
+	var s /*e*/N/*f*/
+	for _, x := range xs {
+		s += x
+	}
+	return s
+}
+
+type List[T fmt.Stringer] struct {
+	items []T
+}
+
+func (l *List[T]) Push(x T) { l./*i*/items/*j*/ = append(l.items, x) }
+
+type name string
+
+func (n name) String() string { return string(n) }
+
+func use() {
+	_ = /*a*/Map/*b*/([]int{1}, func(x int) string { return fmt.Sprint(x) })
+	_ = Sum([]int{1, 2})
+	var l /*c*/List/*d*/[name]
+	l./*k*/Push/*l*/("x")
+	/*m*/l.Push/*n*/("y")
+	l2 := l
+	_ = /*g*/l2/*h*/
+	_ = l./*o*/items/*p*/
+}
+`
+
+const builtinGenericFixture = `package testfixture8
+
+func compare[T /*q*/comparable/*r*/](x, y T) bool {
+	return x == y
+}
+
+func first(xs ...any) /*s*/any/*t*/ {
+	return xs[0]
+}
+`
+
+// writeTestModule writes files into a new module in a temporary directory
+// and returns the directory.
+func writeTestModule(t *testing.T, module string, files map[string]string) string {
+	tmpdir, err := ioutil.TempDir(os.TempDir(), "go2def-test-")
+	must(err)
+	must(ioutil.WriteFile(filepath.Join(tmpdir, "go.mod"), []byte("module "+module+"\n\ngo 1.18\n"), 0666))
+	for name, src := range files {
+		must(ioutil.WriteFile(filepath.Join(tmpdir, name), []byte(src), 0666))
+	}
+	return tmpdir
+}
+
+func TestGenerics(t *testing.T) {
+	tmpdir := writeTestModule(t, "testfixture8", map[string]string{"generic.go": genericFixture, "builtin.go": builtinGenericFixture})
+	defer safeRemoveAll(tmpdir)
+	path := filepath.Join(tmpdir, "generic.go")
+
+	t.Run("generic-function-call", testDescribe(path, "a", "b", nil, Description{
+		Info{Kind: InfoFunction, Text: "func Map[T, U any](xs []T, f func(T) U) []U"},
+		Info{Kind: InfoGeneric, Text: "instance: testfixture8.Map[int, string]"},
+		Info{Kind: InfoGeneric, Text: "instantiated: func(xs []int, f func(int) string) []string"},
+		Info{Kind: InfoPos, Pos: path + ":18"},
+	}))
+	t.Run("generic-type", testDescribe(path, "c", "d", nil, Description{
+		Info{Kind: InfoType, Text: "type: testfixture8.List[T fmt.Stringer]", Pos: path + ":34"},
+		Info{Kind: InfoGeneric, Text: "instance: testfixture8.List[testfixture8.name]"},
+		Info{Kind: InfoPos, Pos: path + ":34"},
+	}))
+	t.Run("type-parameter", testDescribe(path, "e", "f", nil, Description{
+		Info{Kind: InfoObject, Text: "type parameter N testfixture8.SignedNumber"},
+		Info{Kind: InfoType, Text: "type: N"},
+		Info{Kind: InfoGeneric, Text: "constraint: testfixture8.SignedNumber", Pos: path + ":13"},
+		Info{Kind: InfoGeneric, Text: "type set: ~int | ~int64"},
+		Info{Kind: InfoPos, Pos: path + ":26"},
+	}))
+	t.Run("instantiated-type", testDescribe(path, "g", "h", nil, Description{
+		Info{Kind: InfoType, Text: "type: testfixture8.List[testfixture8.name]", Pos: path + ":34"},
+		Info{Kind: InfoGeneric, Text: "generic: testfixture8.List[T fmt.Stringer]", Pos: path + ":34"},
+		Info{Kind: InfoExpr, Text: "l2 := l"},
+		Info{Kind: InfoPos, Pos: path + ":50"},
+	}))
+	// the layout of a struct containing type parameters is not defined
+	t.Run("generic-field", testDescribe(path, "i", "j", nil, Description{
+		Info{Kind: InfoType, Text: "type: []T"},
+		Info{Kind: InfoPos, Pos: path + ":35"},
+	}))
+	t.Run("instantiated-method", testDescribe(path, "k", "l", nil, Description{
+		Info{Kind: InfoFunction, Text: "func (l *List[T]) Push(x T)"},
+		Info{Kind: InfoGeneric, Text: "instance: testfixture8.List[testfixture8.name]"},
+		Info{Kind: InfoGeneric, Text: "instantiated: func (*testfixture8.List[testfixture8.name]).Push(x testfixture8.name)"},
+		Info{Kind: InfoPos, Pos: path + ":38"},
+	}))
+	t.Run("instantiated-method-selector", testDescribe(path, "m", "n", nil, Description{
+		Info{Kind: InfoFunction, Text: "func (l *List[T]) Push(x T)"},
+		Info{Kind: InfoGeneric, Text: "instance: testfixture8.List[testfixture8.name]"},
+		Info{Kind: InfoGeneric, Text: "instantiated: func (*testfixture8.List[testfixture8.name]).Push(x testfixture8.name)"},
+		Info{Kind: InfoPos, Pos: path + ":38"},
+	}))
+	t.Run("instantiated-field", testDescribe(path, "o", "p", nil, Description{
+		Info{Kind: InfoType, Text: "type: []testfixture8.name"},
+		Info{Kind: InfoField, Text: fmt.Sprintf("offset: 0, size: %d, align: %d", 3*strconv.IntSize/8, strconv.IntSize/8)},
+		Info{Kind: InfoGeneric, Text: "instance: testfixture8.List[testfixture8.name]"},
+		Info{Kind: InfoGeneric, Text: "instantiated: field items []testfixture8.name"},
+		Info{Kind: InfoPos, Pos: path + ":35"},
+	}))
+
+	builtinPath := filepath.Join(tmpdir, "builtin.go")
+	t.Run("comparable", testDescribe(builtinPath, "q", "r", nil, Description{
+		Info{Kind: InfoObject, Text: "type comparable interface{comparable}"},
+		Info{Kind: InfoDoc, Text: "@comparable is an interface that is implemented by all comparable types"},
+		Info{Kind: InfoPos, Pos: "src/builtin/builtin.go:"},
+	}))
+	t.Run("any", testDescribe(builtinPath, "s", "t", nil, Description{
+		Info{Kind: InfoObject, Text: "type any = interface{}"},
+		Info{Kind: InfoDoc, Text: "@any is an alias for interface{}"},
+		Info{Kind: InfoPos, Pos: "src/builtin/builtin.go:"},
+	}))
+}
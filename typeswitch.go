@@ -0,0 +1,173 @@
+package go2def
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// typeSwitchOf returns the type switch and the case clause where obj is
+// implicitly declared, if obj is the symbol of a type switch.
+func typeSwitchOf(pkg *packages.Package, obj types.Object) (*ast.TypeSwitchStmt, *ast.CaseClause) {
+	if _, isvar := obj.(*types.Var); !isvar || obj.Pkg() != pkg.Types {
+		return nil, nil
+	}
+	for _, file := range pkg.Syntax {
+		if obj.Pos() < file.Pos() || obj.Pos() >= file.End() {
+			continue
+		}
+		var sw *ast.TypeSwitchStmt
+		var clause *ast.CaseClause
+		ast.Inspect(file, func(node ast.Node) bool {
+			if sw != nil || node == nil || obj.Pos() < node.Pos() || obj.Pos() >= node.End() {
+				return false
+			}
+			if node, isswitch := node.(*ast.TypeSwitchStmt); isswitch {
+				for _, stmt := range node.Body.List {
+					if pkg.TypesInfo.Implicits[stmt] == obj {
+						sw, clause = node, stmt.(*ast.CaseClause)
+					}
+				}
+			}
+			return true
+		})
+		return sw, clause
+	}
+	return nil, nil
+}
+
+// typeSwitchGuard returns the expression whose type is switched on by sw.
+func typeSwitchGuard(sw *ast.TypeSwitchStmt) ast.Expr {
+	var x ast.Expr
+	switch stmt := sw.Assign.(type) {
+	case *ast.ExprStmt:
+		x = stmt.X
+	case *ast.AssignStmt:
+		x = stmt.Rhs[0]
+	}
+	if ta, isassert := unparen(x).(*ast.TypeAssertExpr); isassert {
+		return ta.X
+	}
+	return nil
+}
+
+// describeTypeSwitchVar adds obj, the variable declared by the symbol of sw
+// in clause, to the description with the type narrowed by the clause and the
+// position of the switch.
+func describeTypeSwitchVar(ctx *context, pkg *packages.Package, obj types.Object, sw *ast.TypeSwitchStmt, clause *ast.CaseClause) {
+	ctx.out.object(obj)
+	describeType(ctx, "type:", obj.Type())
+	switch len(clause.List) {
+	case 0:
+		ctx.out.expr("not narrowed, default clause: same type as the switch operand")
+	case 1:
+	default:
+		ctx.out.expr("not narrowed, the case lists more than one type")
+	}
+	ctx.out.typ("type switch:", printerSprint(ctx.getFileSet(sw.Pos()), sw.Assign), ctx.position(sw.Pos()))
+	ctx.out.pos(ctx.position(obj.Pos()))
+}
+
+// describeTypeSwitch adds the types of the case clauses of sw to the
+// description. If the switch is on a value of a named interface type the
+// named types known to implement the interface that aren't handled by any
+// case are also listed.
+func describeTypeSwitch(ctx *context, pkg *packages.Package, sw *ast.TypeSwitchStmt) {
+	guard := typeSwitchGuard(sw)
+	if guard == nil {
+		return
+	}
+	describeType(ctx, "switch on:", pkg.TypesInfo.Types[guard].Type)
+
+	cases := []types.Type{}
+	hasDefault := false
+	for _, stmt := range sw.Body.List {
+		clause := stmt.(*ast.CaseClause)
+		if clause.List == nil {
+			hasDefault = true
+		}
+		for _, expr := range clause.List {
+			typ := pkg.TypesInfo.Types[expr].Type
+			if typ == nil {
+				continue
+			}
+			cases = append(cases, typ)
+			pos := ctx.position(expr.Pos())
+			if named, isnamed := derefNamed(typ); isnamed && named.Obj().Pkg() != nil {
+				pos = ctx.position(named.Obj().Pos())
+			}
+			ctx.out.typ("case:", printTypesTypeNice(typ), pos)
+		}
+	}
+	if hasDefault {
+		ctx.out.expr("default case")
+	}
+
+	if named, isnamed := pkg.TypesInfo.Types[guard].Type.(*types.Named); isnamed {
+		if iface, isiface := named.Underlying().(*types.Interface); isiface && iface.NumMethods() > 0 {
+			describeTypeSwitchCoverage(ctx, named, cases, hasDefault)
+		}
+	}
+
+	ctx.out.pos(ctx.position(sw.Pos()))
+}
+
+// describeTypeSwitchCoverage lists the implementations of the interface
+// named that are not handled by any of the case types. Only the types of
+// packages that can refer to the interface are considered. If the switch
+// has a default clause they are reported as handled by it.
+func describeTypeSwitchCoverage(ctx *context, named *types.Named, cases []types.Type, hasDefault bool) {
+	iface := named.Underlying().(*types.Interface)
+	covered := func(t types.Type) bool {
+		for _, c := range cases {
+			if types.Identical(c, t) {
+				return true
+			}
+			if ciface, isiface := c.Underlying().(*types.Interface); isiface && types.Implements(t, ciface) {
+				return true
+			}
+		}
+		return false
+	}
+
+	missing := 0
+	for _, t := range visibleNamedTypes(ctx, named.Obj().Pkg()) {
+		if types.IsInterface(t) {
+			continue
+		}
+		var impl types.Type
+		switch {
+		case types.Implements(t, iface):
+			impl = t
+			if covered(types.NewPointer(t)) {
+				// both T and *T implement iface, either is enough
+				continue
+			}
+		case types.Implements(types.NewPointer(t), iface):
+			impl = types.NewPointer(t)
+		default:
+			continue
+		}
+		if !covered(impl) {
+			if hasDefault {
+				ctx.out.implements("handled by default case: "+printTypesTypeNice(impl), ctx.position(t.Obj().Pos()))
+			} else {
+				ctx.out.implements("not covered: "+printTypesTypeNice(impl), ctx.position(t.Obj().Pos()))
+			}
+			missing++
+		}
+	}
+	if missing == 0 {
+		ctx.out.expr("all known implementations are covered")
+	}
+}
+
+// derefNamed returns the named type typ or typ points to.
+func derefNamed(typ types.Type) (*types.Named, bool) {
+	if ptyp, isptr := typ.(*types.Pointer); isptr {
+		typ = ptyp.Elem()
+	}
+	named, isnamed := typ.(*types.Named)
+	return named, isnamed
+}
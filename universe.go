@@ -0,0 +1,88 @@
+package go2def
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+)
+
+// describeUniverse adds obj, an object of the universe scope or the Error
+// method of error, to the description. Its declaration and documentation
+// are taken from $GOROOT/src/builtin/builtin.go.
+func describeUniverse(ctx *context, obj types.Object) {
+	fset := token.NewFileSet()
+	decl, doc := builtinDecl(ctx, fset, obj)
+
+	if fndecl, isfunc := decl.(*ast.FuncDecl); isfunc {
+		// the header already includes the documentation
+		ctx.out.funcHeader(fset, fndecl)
+	} else {
+		ctx.out.object(obj)
+		if c, isconst := obj.(*types.Const); isconst {
+			ctx.out.value(constantString(c.Val()))
+		}
+		if doc != nil {
+			ctx.out.doc(doc.Text())
+		}
+	}
+	if decl != nil {
+		ctx.out.pos(fset.Position(decl.Pos()))
+	}
+}
+
+// builtinDecl returns the node declaring obj in $GOROOT/src/builtin/builtin.go
+// and its documentation.
+func builtinDecl(ctx *context, fset *token.FileSet, obj types.Object) (ast.Node, *ast.CommentGroup) {
+	file, err := parser.ParseFile(fset, filepath.Join(ctx.Goroot(), "src", "builtin", "builtin.go"), nil, parser.ParseComments)
+	if err != nil {
+		return nil, nil
+	}
+
+	name := obj.Name()
+	method := ""
+	if _, isfunc := obj.(*types.Func); isfunc {
+		// the only method declared in the universe scope is error.Error
+		method, name = name, "error"
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if _, isbuiltin := obj.(*types.Builtin); isbuiltin && decl.Name.Name == name {
+				return decl, decl.Doc
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.Name != name {
+						continue
+					}
+					if method == "" {
+						return spec, specDoc(spec.Doc, spec.Comment, decl)
+					}
+					iface, isiface := spec.Type.(*ast.InterfaceType)
+					if !isiface {
+						continue
+					}
+					for _, field := range iface.Methods.List {
+						for _, id := range field.Names {
+							if id.Name == method {
+								return field, specDoc(field.Doc, field.Comment, nil)
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					for _, id := range spec.Names {
+						if id.Name == name && method == "" {
+							return spec, specDoc(spec.Doc, spec.Comment, decl)
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,163 @@
+package go2def
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aarzilli/go2def/visit"
+	"golang.org/x/tools/go/packages"
+)
+
+// moduleRoot returns the directory containing the go.mod file of the module
+// dir belongs to, or dir itself if it isn't in a module.
+func moduleRoot(dir string) string {
+	for cur := dir; ; {
+		if _, err := os.Stat(filepath.Join(cur, "go.mod")); err == nil {
+			return cur
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return dir
+		}
+		cur = parent
+	}
+}
+
+// loadWorkspace loads, with syntax, all the packages of the module that
+// contains path. If tests is set test packages are loaded too.
+func loadWorkspace(ctx *context, path string, tests bool) error {
+	ctx.currentFileSet = token.NewFileSet()
+	cfg := &packages.Config{
+		Mode:      packages.LoadSyntax,
+		Dir:       moduleRoot(ctx.Wd),
+		Fset:      ctx.currentFileSet,
+		ParseFile: ctx.parseFile(),
+	}
+	decorateConfig(ctx, cfg)
+	if tests {
+		cfg.Tests = true
+	}
+	var err error
+	ctx.pkgs, err = ctx.load(cfg, "./...")
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, pkg := range ctx.pkgs {
+		for _, filename := range pkg.CompiledGoFiles {
+			if filename == path {
+				found = true
+			}
+		}
+	}
+	if !found {
+		// path is outside of the module (or excluded by ./...), add its
+		// package to the workspace.
+		pkgs, err := ctx.load(cfg, "file="+path)
+		if err != nil {
+			return err
+		}
+		ctx.pkgs = append(ctx.pkgs, pkgs...)
+	}
+	return nil
+}
+
+// objectOfNode returns the object referred to by node, which should be an
+// identifier or a selector expression. The symbol of a type switch refers to
+// the object declared in its first case clause.
+func objectOfNode(pkg *packages.Package, node ast.Node) types.Object {
+	switch node := node.(type) {
+	case *ast.Ident:
+		if obj := pkg.TypesInfo.Uses[node]; obj != nil {
+			return obj
+		}
+		if obj := pkg.TypesInfo.Defs[node]; obj != nil {
+			return obj
+		}
+		if file := fileOf(pkg, node); file != nil {
+			if objs := typeSwitchObjects(pkg, file, node); len(objs) > 0 {
+				return objs[0]
+			}
+		}
+		return nil
+	case *ast.SelectorExpr:
+		if sel := pkg.TypesInfo.Selections[node]; sel != nil {
+			return sel.Obj()
+		}
+		return objectOfNode(pkg, node.Sel)
+	}
+	return nil
+}
+
+// objKey identifies an object across packages loaded by the same call to
+// packages.Load, even when it appears in more than one variant of a package
+// (for example a package and its test variant).
+type objKey struct {
+	name     string
+	filename string
+	line     int
+	column   int
+}
+
+func (ctx *context) objKey(obj types.Object) objKey {
+	pos := ctx.getPosition(obj.Pos())
+	return objKey{obj.Name(), pos.Filename, pos.Line, pos.Column}
+}
+
+// objectAt loads the workspace of path and returns the object at pos.
+func objectAt(ctx *context, path string, pos [2]int, tests bool) (*packages.Package, types.Object, error) {
+	if err := loadWorkspace(ctx, path, tests); err != nil {
+		return nil, nil, fmt.Errorf("loading packages: %v", err)
+	}
+	pkg, node := findNodeAt(ctx, path, pos)
+	if node == nil {
+		return nil, nil, fmt.Errorf("nothing found")
+	}
+	obj := objectOfNode(pkg, node)
+	if obj == nil {
+		return nil, nil, fmt.Errorf("no object at %s", printerSprint(ctx.getFileSet(node.Pos()), node))
+	}
+	return pkg, obj, nil
+}
+
+// workspacePackages calls fn for every package of the workspace that has
+// syntax.
+func workspacePackages(ctx *context, fn func(pkg *packages.Package)) {
+	pkgit := visit.Packages(ctx.pkgs)
+	for pkgit.Next() {
+		pkg := pkgit.Pkg()
+		if pkg == nil || pkg.TypesInfo == nil || len(pkg.Syntax) == 0 {
+			continue
+		}
+		fn(pkg)
+	}
+}
+
+// sourceLine returns the text of the line at pos, without leading and
+// trailing spaces.
+func (ctx *context) sourceLine(pos token.Position, files map[string][]byte) string {
+	buf, ok := files[pos.Filename]
+	if !ok {
+		buf, ok = ctx.Modfiles[pos.Filename]
+		if !ok {
+			buf, _ = ioutil.ReadFile(pos.Filename)
+		}
+		files[pos.Filename] = buf
+	}
+	off, ok := lineOffset(buf, pos.Line)
+	if !ok {
+		return ""
+	}
+	line := buf[off:]
+	if nl := bytes.IndexByte(line, '\n'); nl >= 0 {
+		line = line[:nl]
+	}
+	return strings.TrimSpace(string(line))
+}